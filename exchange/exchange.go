@@ -0,0 +1,336 @@
+// Package exchange converts session lists to and from the formats of other
+// tools, for `rolo export` and `rolo import`
+package exchange
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"rolo/storage"
+)
+
+// Format is an export and import format
+type Format string
+
+const (
+	// Text is one session name per line, as in rolo.txt
+	Text Format = "txt"
+	// JSON is a rolo.json document, keeping entry status and metadata
+	JSON Format = "json"
+	// Tmuxinator is a stream of tmuxinator project files
+	Tmuxinator Format = "tmuxinator"
+	// Tmuxp is a stream of tmuxp workspace files
+	Tmuxp Format = "tmuxp"
+)
+
+// Formats lists the supported formats
+var Formats = []Format{Text, JSON, Tmuxinator, Tmuxp}
+
+// ParseFormat validates a format name
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = string(format)
+	}
+	return "", fmt.Errorf("unknown format '%s', expected one of %s", name, strings.Join(names, ", "))
+}
+
+// tmuxinatorProject is the part of a tmuxinator project rolo reads and writes
+type tmuxinatorProject struct {
+	Name    string           `yaml:"name"`
+	Root    string           `yaml:"root,omitempty"`
+	Windows []map[string]any `yaml:"windows"`
+}
+
+// tmuxpWorkspace is the part of a tmuxp workspace rolo reads and writes
+type tmuxpWorkspace struct {
+	SessionName    string        `yaml:"session_name"`
+	StartDirectory string        `yaml:"start_directory,omitempty"`
+	Windows        []tmuxpWindow `yaml:"windows"`
+}
+
+type tmuxpWindow struct {
+	WindowName string `yaml:"window_name"`
+	Panes      []any  `yaml:"panes"`
+}
+
+// Export renders sessions in format. Only JSON keeps entries that aren't active, the
+// other formats describe the sessions to run. tmux session ids are left out,
+// they mean nothing to another server.
+func Export(w io.Writer, format Format, sessions []storage.SessionData) error {
+	sessions = append([]storage.SessionData{}, sessions...)
+	for i := range sessions {
+		sessions[i].ID, sessions[i].Created = "", time.Time{}
+	}
+
+	switch format {
+	case Text:
+		for _, session := range sessions {
+			if session.Active() {
+				if _, err := fmt.Fprintln(w, session.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case JSON:
+		store, err := storage.StoreFor("export.json")
+		if err != nil {
+			return err
+		}
+		data, err := storage.EncodeList(store, storage.Metadata{}, sessions)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+
+	case Tmuxinator, Tmuxp:
+		// One YAML document per session, in order
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		for _, session := range sessions {
+			if !session.Active() {
+				continue
+			}
+			var doc any
+			if format == Tmuxinator {
+				doc = tmuxinatorProject{
+					Name:    session.Name,
+					Root:    session.Dir,
+					Windows: []map[string]any{{"main": nil}},
+				}
+			} else {
+				doc = tmuxpWorkspace{
+					SessionName:    session.Name,
+					StartDirectory: session.Dir,
+					Windows:        []tmuxpWindow{{WindowName: "main", Panes: []any{nil}}},
+				}
+			}
+			if err := enc.Encode(doc); err != nil {
+				return err
+			}
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+// Detect guesses the format of data, using the file name when it has a
+// telling extension. Input that isn't recognised is taken to be text.
+func Detect(name string, data []byte) Format {
+	trimmed := bytes.TrimSpace(data)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return JSON
+	case ".txt":
+		return Text
+	}
+
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return JSON
+	}
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		switch {
+		case strings.HasPrefix(line, "session_name:"):
+			return Tmuxp
+		case strings.HasPrefix(line, "name:"), strings.HasPrefix(line, "project_name:"):
+			return Tmuxinator
+		}
+	}
+	return Text
+}
+
+// Import parses sessions from data in format
+func Import(data []byte, format Format) ([]storage.SessionData, error) {
+	switch format {
+	case Text:
+		var sessions []storage.SessionData
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			sessions = append(sessions, storage.SessionData{Name: line})
+		}
+		return sessions, nil
+
+	case JSON:
+		store, err := storage.StoreFor("import.json")
+		if err != nil {
+			return nil, err
+		}
+		return storage.DecodeList(store, data)
+
+	case Tmuxinator, Tmuxp:
+		var sessions []storage.SessionData
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc map[string]any
+			err := dec.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s file: %w", format, err)
+			}
+			if doc == nil {
+				continue
+			}
+
+			nameKey, dirKey := "name", "root"
+			if format == Tmuxp {
+				nameKey, dirKey = "session_name", "start_directory"
+			}
+			name, _ := doc[nameKey].(string)
+			if name == "" && format == Tmuxinator {
+				// Older tmuxinator projects used project_name and project_root
+				name, _ = doc["project_name"].(string)
+				if dir, ok := doc["project_root"].(string); ok && doc[dirKey] == nil {
+					doc[dirKey] = dir
+				}
+			}
+			if name == "" {
+				return nil, fmt.Errorf("%s file without %s", format, nameKey)
+			}
+
+			session := storage.SessionData{Name: name}
+			if dir, ok := doc[dirKey].(string); ok {
+				if err := session.SetMeta("dir", dir); err != nil {
+					return nil, err
+				}
+			}
+			sessions = append(sessions, session)
+		}
+		return sessions, nil
+	}
+	return nil, fmt.Errorf("unknown format '%s'", format)
+}
+
+// Merge adds imported sessions missing from the list to its end. Sessions
+// already in the list keep their place and status, and pick up the
+// metadata fields the import sets.
+func Merge(current, imported []storage.SessionData) []storage.SessionData {
+	merged := append([]storage.SessionData{}, current...)
+	for _, session := range imported {
+		index := findName(merged, session.Name)
+		if index == -1 {
+			session.ID, session.Created = "", time.Time{}
+			merged = append(merged, session)
+			continue
+		}
+		mergeMeta(&merged[index], session)
+	}
+	return merged
+}
+
+// Replace returns the imported list without duplicate names. Sessions that
+// are already in the current list keep their tmux id, so renames are still
+// followed, and the metadata fields the import doesn't set.
+func Replace(current, imported []storage.SessionData) []storage.SessionData {
+	replaced := make([]storage.SessionData, 0, len(imported))
+	for _, session := range imported {
+		if findName(replaced, session.Name) != -1 {
+			continue
+		}
+		if index := findName(current, session.Name); index != -1 {
+			existing := current[index]
+			existing.Status, existing.StatusSince = session.Status, session.StatusSince
+			mergeMeta(&existing, session)
+			session = existing
+		} else {
+			session.ID, session.Created = "", time.Time{}
+		}
+		replaced = append(replaced, session)
+	}
+	return replaced
+}
+
+// findName returns the index of the entry named name, or -1
+func findName(sessions []storage.SessionData, name string) int {
+	for i, session := range sessions {
+		if session.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeMeta copies the metadata fields set on from to to
+func mergeMeta(to *storage.SessionData, from storage.SessionData) {
+	for _, field := range storage.MetaFields {
+		if value := from.GetMeta(field); value != "" {
+			to.SetMeta(field, value)
+		}
+	}
+}
+
+// Diff describes how after differs from before, one line per entry of after
+// in order, followed by the entries that were dropped. Lines are marked "+"
+// when added, "~" when moved or the status or metadata changed, "-" when
+// removed and indented with a space when unchanged.
+func Diff(before, after []storage.SessionData) []string {
+	beforeIndex := make(map[string]int, len(before))
+	for i, session := range before {
+		beforeIndex[session.Name] = i
+	}
+
+	// Entries only count as moved when their order relative to the other
+	// kept entries changes, not when something is inserted before them
+	keptBefore := make([]string, 0, len(before))
+	afterNames := make(map[string]bool, len(after))
+	for _, session := range after {
+		afterNames[session.Name] = true
+	}
+	for _, session := range before {
+		if afterNames[session.Name] {
+			keptBefore = append(keptBefore, session.Name)
+		}
+	}
+
+	lines := make([]string, 0, len(after)+len(before))
+	k := 0
+	for _, session := range after {
+		label := session.Name
+		if !session.Active() {
+			label += " (" + string(session.State()) + ")"
+		}
+
+		i, ok := beforeIndex[session.Name]
+		if !ok {
+			lines = append(lines, "+ "+label)
+			continue
+		}
+
+		old := before[i]
+		old.ID, session.ID = "", ""
+		old.Created, session.Created = time.Time{}, time.Time{}
+		moved := keptBefore[k] != session.Name
+		k++
+		if moved || !reflect.DeepEqual(old, session) {
+			lines = append(lines, "~ "+label)
+		} else {
+			lines = append(lines, "  "+label)
+		}
+	}
+
+	for _, session := range before {
+		if !afterNames[session.Name] {
+			lines = append(lines, "- "+session.Name)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,155 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rolo/storage"
+)
+
+// testSessions returns an active session with a directory, a hidden one and
+// a plain active one
+func testSessions(t *testing.T) []storage.SessionData {
+	t.Helper()
+	api := storage.SessionData{ID: "$1", Name: "api", Created: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	if err := api.SetMeta("dir", "/src/api"); err != nil {
+		t.Fatal(err)
+	}
+	return []storage.SessionData{
+		api,
+		{Name: "old", Status: storage.StatusHidden, StatusSince: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "web"},
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	tests := []struct {
+		format Format
+		// contains is part of the export
+		contains string
+		// names and dirs are what importing the export gives back
+		names []string
+		dirs  []string
+	}{
+		{format: Text, contains: "api\nweb\n", names: []string{"api", "web"}, dirs: []string{"", ""}},
+		{format: JSON, contains: `"status": "hidden"`, names: []string{"api", "old", "web"}, dirs: []string{"/src/api", "", ""}},
+		{format: Tmuxinator, contains: "name: api\nroot: /src/api\n", names: []string{"api", "web"}, dirs: []string{"/src/api", ""}},
+		{format: Tmuxp, contains: "session_name: api\nstart_directory: /src/api\n", names: []string{"api", "web"}, dirs: []string{"/src/api", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(&buf, tt.format, testSessions(t)); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			exported := buf.String()
+			if !strings.Contains(exported, tt.contains) {
+				t.Errorf("export doesn't contain %q:\n%s", tt.contains, exported)
+			}
+			if strings.Contains(exported, "$1") {
+				t.Errorf("export kept the tmux session id:\n%s", exported)
+			}
+
+			if detected := Detect("sessions", buf.Bytes()); detected != tt.format {
+				t.Errorf("Detect = %s, want %s", detected, tt.format)
+			}
+			imported, err := Import(buf.Bytes(), tt.format)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			var names, dirs []string
+			for _, session := range imported {
+				names = append(names, session.Name)
+				dirs = append(dirs, session.GetMeta("dir"))
+			}
+			if !reflect.DeepEqual(names, tt.names) || !reflect.DeepEqual(dirs, tt.dirs) {
+				t.Errorf("Import = %v with dirs %v, want %v with %v", names, dirs, tt.names, tt.dirs)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		names  []string
+		err    bool
+	}{
+		{name: "text skips comments and blanks", data: "# work\napi\n\n  web  \n", format: Text, names: []string{"api", "web"}},
+		{name: "legacy json array", data: `[{"name": "api"}, {"name": "db", "deleted": true}]`, format: JSON, names: []string{"api", "db"}},
+		{name: "old tmuxinator project", data: "project_name: api\nproject_root: /src/api\n", format: Tmuxinator, names: []string{"api"}},
+		{name: "tmuxinator without a name", data: "root: /src\n", format: Tmuxinator, err: true},
+		{name: "unknown format", data: "api\n", format: "csv", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imported, err := Import([]byte(tt.data), tt.format)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Import = %+v, want an error", imported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			var names []string
+			for _, session := range imported {
+				names = append(names, session.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("Import = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestMergeAndReplace(t *testing.T) {
+	current := testSessions(t)
+	imported := []storage.SessionData{{Name: "web"}, {Name: "new"}, {Name: "api"}, {Name: "new"}}
+	if err := imported[0].SetMeta("dir", "/src/web"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		apply func(current, imported []storage.SessionData) []storage.SessionData
+		names []string
+	}{
+		{name: "merge", apply: Merge, names: []string{"api", "old", "web", "new"}},
+		{name: "replace", apply: Replace, names: []string{"web", "new", "api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.apply(current, imported)
+			var names []string
+			for _, session := range result {
+				names = append(names, session.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Fatalf("%s = %v, want %v", tt.name, names, tt.names)
+			}
+			for _, session := range result {
+				switch session.Name {
+				case "api":
+					// Kept entries keep their tmux id and metadata
+					if session.ID != "$1" || session.GetMeta("dir") != "/src/api" {
+						t.Errorf("api = %+v, want its id and dir kept", session)
+					}
+				case "web":
+					if session.GetMeta("dir") != "/src/web" {
+						t.Errorf("web = %+v, want the imported dir", session)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,227 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"rolo/exchange"
+	"rolo/navigation"
+	"rolo/status"
 	"rolo/storage"
 	"rolo/tmux"
 	"rolo/tui"
 )
 
-func saveOrder(sessions []storage.SessionData) error {
-	return storage.SaveSessionsData(sessions)
+// globalOptions holds the flags accepted by every command
+type globalOptions struct {
+	server tmux.Server
+	// configDir overrides where rolo keeps its files, see storage.SetConfigDir
+	configDir string
+	// list is the session list to use, resolved by resolveList
+	list string
+}
+
+// sessionLock is held while a command loads, modifies and saves the session
+// list, so concurrent rolo processes can't interleave their writes
+var sessionLock *storage.FileLock
+
+// lockSessions takes the session list lock, exiting if it can't be taken
+func lockSessions(opts globalOptions) {
+	if sessionLock != nil {
+		return
+	}
+	lock, err := storage.LockSessions(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sessionLock = lock
+}
+
+// unlockSessions releases the session list lock if it is held
+func unlockSessions() {
+	sessionLock.Unlock()
+	sessionLock = nil
+}
+
+// serverKey returns the storage key of the selected tmux server
+func (o globalOptions) serverKey() string {
+	return o.server.Key()
+}
+
+// listKey returns the storage key of the selected list of the selected server
+func (o globalOptions) listKey() storage.ListKey {
+	return storage.ListKey{Server: o.serverKey(), List: o.list}
+}
+
+// resolveList picks the list to use: the one passed with --list, otherwise
+// the active list, see storage.LoadActiveList
+func resolveList(opts globalOptions) (string, error) {
+	if opts.list != "" {
+		exists, err := storage.ListExists(opts.list)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", fmt.Errorf("list '%s' doesn't exist, create it with 'rolo list new %s'", opts.list, opts.list)
+		}
+		return opts.list, nil
+	}
+
+	active, chosen, err := storage.LoadActiveList()
+	if err != nil {
+		return "", err
+	}
+	if !chosen {
+		// Older versions kept the active list in config.json
+		config, err := storage.LoadConfig()
+		if err != nil {
+			return "", fmt.Errorf("failed to load config: %w", err)
+		}
+		active = config.ActiveList
+	}
+	if active == "" {
+		return storage.DefaultList, nil
+	}
+	if exists, err := storage.ListExists(active); err != nil || !exists {
+		fmt.Fprintf(os.Stderr, "Warning: active list '%s' doesn't exist, using the %s list\n", active, storage.DefaultList)
+		return storage.DefaultList, nil
+	}
+	return active, nil
+}
+
+// parseGlobalFlags extracts global flags from anywhere in args and returns
+// the remaining arguments. The tmux server is detected from $TMUX unless
+// --socket or --socket-name is given. Only long or upper-case flags are
+// global so they don't clash with the flags of individual commands.
+func parseGlobalFlags(args []string) (globalOptions, []string, error) {
+	var opts globalOptions
+	explicitServer := false
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		switch name {
+		case "--config", "--list":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("flag %s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "--config" {
+				opts.configDir = value
+			} else {
+				opts.list = value
+			}
+		case "-S", "--socket", "-L", "--socket-name":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("flag %s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "-S" || name == "--socket" {
+				opts.server.SocketPath = value
+			} else {
+				opts.server.SocketName = value
+			}
+			explicitServer = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if !explicitServer {
+		opts.server = tmux.DetectServer()
+	}
+
+	return opts, rest, nil
 }
 
 func showUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  rolo          - Launch interactive session reorder UI")
+	fmt.Println("                  (enter saves and switches to the selected session)")
 	fmt.Println("  rolo populate - Fetch active tmux sessions and save to config")
-	fmt.Println("  rolo next     - Switch to next session in order")
-	fmt.Println("  rolo prev     - Switch to previous session in order")
+	fmt.Println("  rolo list     - Show the ordered sessions with live tmux details")
+	fmt.Println("  rolo lists    - Show the named session lists, marking the active one")
+	fmt.Println("  rolo list use <name>          - Make a list the active one")
+	fmt.Println("  rolo list new <name> [--copy] - Create a list, --copy starts from the active one")
+	fmt.Println("  rolo list rm <name>           - Remove a list")
+	fmt.Println("  rolo next [--group] [--strategy <name>]")
+	fmt.Println("                - Switch to next session in order, --group keeps to")
+	fmt.Println("                  the current session's group and --strategy picks the")
+	fmt.Println("                  order: stored, alphabetical, mru, frecency, last-activity")
+	fmt.Println("  rolo prev [--group] [--strategy <name>]")
+	fmt.Println("                - Switch to previous session in order, flags as for next")
+	fmt.Println("  rolo next-group - Switch to the first session of the next group")
+	fmt.Println("  rolo prev-group - Switch to the first session of the previous group")
+	fmt.Println("  rolo goto <n|name> - Switch to the nth session in order, or by name")
+	fmt.Println("  rolo last     - Switch back to the previously visited session")
+	fmt.Println("  rolo back [n] - Go back n sessions in this client's navigation stack")
+	fmt.Println("  rolo forward [n] - Go forward again after going back")
+	fmt.Println("  rolo kill [--tombstone] [name]")
+	fmt.Println("                - Kill a session (default: current), handing its clients")
+	fmt.Println("                  to the next session in order")
+	fmt.Println("  rolo status [--current <name>] [--max-width <n>] [--separator <s>]")
+	fmt.Println("                - Print the ordered list as a tmux status line format")
+	fmt.Println("  rolo bindings [--no-prefix] [--modifier <mod>]")
+	fmt.Println("                - Print tmux.conf bind-key lines for slots 1-9")
+	fmt.Println("  rolo meta set <n|name> <field>=<value>... - Describe a session, fields:")
+	fmt.Println("                  alias, dir, tags (comma separated), notes, pinned, color, group")
+	fmt.Println("  rolo meta unset <n|name> <field>...       - Clear fields")
+	fmt.Println("  rolo meta show <n|name>                   - Show a session's fields")
+	fmt.Println("  rolo export [--format txt|json|tmuxinator|tmuxp] [--output <file>]")
+	fmt.Println("                - Write the list in another format (default json)")
+	fmt.Println("  rolo import [--format <format>] [--replace] [--dry-run] <file|->")
+	fmt.Println("                - Merge (or replace) the list with one read from a file or")
+	fmt.Println("                  stdin, printing the changes")
+	fmt.Println("  rolo undo [n]  - Restore the order from before the last n saves")
+	fmt.Println("  rolo redo [n]  - Reapply n undone saves")
+	fmt.Println("  rolo history  - Show the saved orders undo and redo move through")
+	fmt.Println("  rolo doctor [--fix] - Find sessions listed more than once and entries")
+	fmt.Println("                  without a name, --fix keeps the first entry of each")
+	fmt.Println("  rolo config get [key]         - Show the settings, or one setting")
+	fmt.Println("  rolo config set <key> <value> - Change a setting, e.g. status.max_width 60")
+	fmt.Println("  rolo config edit              - Open the config in $EDITOR and validate it")
+	fmt.Println("  rolo config validate          - Report unknown keys and wrong types")
+	fmt.Println("  rolo config show-defaults [--format json|toml|yaml]")
+	fmt.Println("  rolo config schema            - Print the JSON Schema of the config")
+	fmt.Println("  rolo migrate [--check]")
+	fmt.Println("                - Upgrade saved session lists to the current format")
+	fmt.Println("                  (--check only reports what would change)")
 	fmt.Println("  rolo help     - Show this help message")
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("  -S, --socket <path>       - Use the tmux server at this socket path")
+	fmt.Println("  -L, --socket-name <name>  - Use the tmux server with this socket name")
+	fmt.Println("  --list <name>             - Use this session list instead of the active one")
+	fmt.Println("  --config <dir>            - Keep all rolo files in this directory")
+	fmt.Println("                              (also $ROLO_CONFIG_DIR)")
+	fmt.Println()
+	fmt.Println("Without these flags the server is detected from $TMUX, and each server")
+	fmt.Println("keeps its own ordered session list.")
 }
 
-func handlePopulate() {
-	sessions, err := tmux.GetActiveSessions()
+func handlePopulate(client tmux.Client, opts globalOptions) {
+	lockSessions(opts)
+	defer unlockSessions()
+
+	sessions, err := client.ListSessions()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting tmux sessions: %v\n", err)
 		os.Exit(1)
@@ -34,104 +232,439 @@ func handlePopulate() {
 		return
 	}
 
-	// Convert to SessionData format (all non-deleted by default)
+	// Metadata and pinned sessions carry over from the current list
+	previous, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Convert to SessionData format (all active by default)
 	sessionData := make([]storage.SessionData, len(sessions))
-	for i, name := range sessions {
-		sessionData[i] = storage.SessionData{Name: name, Deleted: false}
+	for i, session := range sessions {
+		sessionData[i] = storage.SessionData{ID: session.ID, Name: session.Name, Created: session.Created}
 	}
+	sessionData = storage.Repopulate(previous, sessionData)
 
-	if err := storage.SaveSessionsData(sessionData); err != nil {
+	if err := storage.SaveSessionsData(opts.listKey(), sessionData, storage.CausePopulate); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving sessions: %v\n", err)
 		os.Exit(1)
 	}
 
-	configPath, _ := storage.GetConfigJSONPath()
-	fmt.Printf("Saved %d session(s) to %s:\n", len(sessions), configPath)
-	for _, session := range sessions {
-		fmt.Printf("  - %s\n", session)
+	configPath, _ := storage.GetListPath(opts.listKey())
+	fmt.Printf("Saved %d session(s) to %s:\n", len(sessionData), configPath)
+	for i, session := range sessionData {
+		if i >= len(sessions) {
+			fmt.Printf("  - %s (pinned)\n", session.Name)
+			continue
+		}
+		fmt.Printf("  - %s\n", session.Name)
+	}
+}
+
+func handleList(client tmux.Client, opts globalOptions) {
+	lockSessions(opts)
+	defer unlockSessions()
+
+	live, err := client.ListSessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting tmux sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessions = applyLive(opts, sessions, live)
+
+	if opts.list != storage.DefaultList {
+		fmt.Printf("List: %s\n\n", opts.list)
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	// The group column is only shown once groups are used
+	groups := storage.HasGroups(sessions)
+	groupColumn := func(value string) string {
+		if !groups {
+			return ""
+		}
+		return value + "\t"
+	}
+	fmt.Fprintln(w, "#\tSESSION\t"+groupColumn("GROUP")+"STATE\tWINDOWS\tATTACHED\tIDLE\tAGE\tTAGS\tPATH\tNOTES")
+
+	tracked := make(map[string]bool, len(sessions))
+	for i, session := range sessions {
+		info, ok := tmux.FindSession(live, session.ID, session.Name)
+		if ok {
+			tracked[info.ID] = true
+		}
+
+		state := string(session.State())
+		if session.Active() && !ok {
+			// Not seen missing by rolo yet, e.g. killed outside a reconcile
+			state = "not running"
+		} else if !session.Active() && !session.StatusSince.IsZero() {
+			state += " " + tmux.FormatAge(now.Sub(session.StatusSince))
+		}
+
+		columns := "-\t-\t-\t-"
+		path := session.Dir
+		if ok {
+			columns = sessionColumns(info, now)
+			path = info.Path
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s%s\t%s\t%s\t%s\t%s\n", i+1, entryLabel(session), groupColumn(orDash(session.Group)), state, columns,
+			orDash(strings.Join(session.Tags, ",")), orDash(path), truncateNotes(session.Notes))
+	}
+
+	// Sessions running in tmux that aren't in the ordered list yet
+	for _, info := range live {
+		if !tracked[info.ID] {
+			fmt.Fprintf(w, "-\t%s\t%s%s\t%s\t-\t%s\t\n", info.Name, groupColumn("-"), "untracked", sessionColumns(info, now), info.Path)
+		}
+	}
+
+	w.Flush()
+}
+
+// entryLabel names a list entry for display: its alias with the session
+// name, and a star when pinned
+func entryLabel(session storage.SessionData) string {
+	label := session.Name
+	if session.Alias != "" {
+		label = fmt.Sprintf("%s (%s)", session.Alias, session.Name)
+	}
+	if session.Pinned {
+		label += " ★"
+	}
+	return label
+}
+
+// orDash returns s, or "-" for an empty table cell
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// truncateNotes shortens notes to the first line and 40 characters
+func truncateNotes(notes string) string {
+	notes, _, multiline := strings.Cut(notes, "\n")
+	if runes := []rune(notes); len(runes) > 40 {
+		return string(runes[:39]) + "…"
+	}
+	if multiline {
+		return notes + "…"
+	}
+	return notes
+}
+
+// sessionColumns formats the live tmux columns of `rolo list`
+func sessionColumns(session tmux.Session, now time.Time) string {
+	idle, age := "-", "-"
+	if !session.Activity.IsZero() {
+		idle = tmux.FormatAge(session.Idle(now))
+	}
+	if !session.Created.IsZero() {
+		age = tmux.FormatAge(now.Sub(session.Created))
 	}
+	return fmt.Sprintf("%d\t%d\t%s\t%s", session.Windows, session.Attached, idle, age)
 }
 
-func findSessionIndex(sessions []storage.SessionData, target string) int {
+// findSessionIndex returns the index of the target session, matching by
+// tmux session id first and falling back to the name
+func findSessionIndex(sessions []storage.SessionData, target tmux.Session) int {
+	if target.ID != "" {
+		for i, session := range sessions {
+			if session.ID == target.ID {
+				return i
+			}
+		}
+	}
 	for i, session := range sessions {
-		if session.Name == target {
+		if session.Name == target.Name {
 			return i
 		}
 	}
 	return -1
 }
 
-func findNextActiveSession(sessions []storage.SessionData, currentIndex int, wrapAround bool) int {
-	if len(sessions) == 0 {
-		return -1
-	}
-	
-	// Determine the search range based on wrapAround setting
-	maxIterations := len(sessions)
-	if !wrapAround {
-		// Only search from current position to end
-		maxIterations = len(sessions) - currentIndex - 1
-	}
-	
-	// Start from the next index
-	for i := 1; i <= maxIterations; i++ {
-		var nextIndex int
-		if wrapAround {
-			// Wrap around to the beginning
-			nextIndex = (currentIndex + i) % len(sessions)
-		} else {
-			// Don't wrap around
-			nextIndex = currentIndex + i
-			if nextIndex >= len(sessions) {
-				break
+// reconcileSessions brings the stored list up to date with tmux, see
+// applyLive. Failures are reported but not fatal.
+func reconcileSessions(client tmux.Client, opts globalOptions, sessions []storage.SessionData) []storage.SessionData {
+	live, err := client.ListSessions()
+	if err != nil {
+		// Outside tmux the server may simply not be running yet
+		if client.Inside() {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to list tmux sessions: %v\n", err)
+		}
+		return sessions
+	}
+	return applyLive(opts, sessions, live)
+}
+
+// applyLive follows sessions renamed in tmux, makes missing sessions that
+// reappeared active again and expires old tombstones, saving each kind of
+// change separately so they can be undone one at a time. Returns the
+// updated list.
+func applyLive(opts globalOptions, sessions []storage.SessionData, live []tmux.Session) []storage.SessionData {
+	config, err := storage.LoadConfig()
+	if err != nil {
+		config = storage.DefaultConfig()
+	}
+
+	save := func(cause storage.Cause) {
+		if err := storage.SaveSessionsData(opts.listKey(), sessions, cause); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to save updated session state: %v\n", err)
+		}
+	}
+
+	now := time.Now()
+	running := navigation.LiveSessions(live)
+	if storage.FollowRenames(sessions, running) {
+		save(storage.CauseRename)
+	}
+	if storage.Resurrect(sessions, running, now) {
+		save(storage.CauseResurrect)
+	}
+	var expired bool
+	if sessions, expired = storage.ExpireTombstones(sessions, config.Tombstones, now); expired {
+		save(storage.CauseExpire)
+	}
+	return sessions
+}
+
+// switchToEntry switches to the session at index, or attaches to it when
+// rolo isn't running inside tmux. If tmux can't switch to it the session is
+// assumed gone: it is marked missing, the list is saved and false is
+// returned so the caller can move on to another entry.
+func switchToEntry(client tmux.Client, opts globalOptions, sessions []storage.SessionData, index int) bool {
+	session := sessions[index]
+	if err := activateSession(client, opts, sessions, index); err != nil {
+		// The session exists, so this is a real failure rather than a stale entry
+		// Activation may have refreshed the id, so re-read the entry
+		session = sessions[index]
+		if exists, _ := client.HasSession(tmux.Target(session.ID, session.Name)); exists {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Log the error and mark session as missing
+		fmt.Fprintf(os.Stderr, "Warning: Session '%s' doesn't exist, skipping: %v\n", session.Name, err)
+		sessions[index].SetState(storage.StatusMissing, time.Now())
+
+		// Save the updated state
+		if saveErr := storage.SaveSessionsData(opts.listKey(), sessions, storage.CauseAutoDelete); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to save updated session state: %v\n", saveErr)
+		}
+		return false
+	}
+	return true
+}
+
+// activateSession switches the current client to the session at index. From
+// outside tmux it attaches instead, and if no server is running yet it starts
+// one by recreating the session under its stored name.
+func activateSession(client tmux.Client, opts globalOptions, sessions []storage.SessionData, index int) error {
+	session := sessions[index]
+	target := tmux.Session{ID: session.ID, Name: session.Name, Created: session.Created}
+
+	if !client.Inside() {
+		if _, err := client.ListSessions(); err != nil {
+			created, err := client.NewSession(session.Name, session.Dir)
+			if err != nil {
+				return err
+			}
+
+			// Ids from the previous server are meaningless now
+			for i := range sessions {
+				sessions[i].ID, sessions[i].Created = "", time.Time{}
+			}
+			sessions[index].ID, sessions[index].Created = created.ID, created.Created
+			if err := storage.SaveSessionsData(opts.listKey(), sessions, storage.CauseServerRestart); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to save updated session state: %v\n", err)
 			}
+			target = created
+		}
+	}
+
+	return switchSession(client, opts, target, true)
+}
+
+// switchSession switches the current client to target, or attaches to it
+// from outside tmux, and records the switch in the server's MRU log. A new
+// navigation is also pushed onto the client's back/forward stack, which
+// back and forward move through instead.
+func switchSession(client tmux.Client, opts globalOptions, target tmux.Session, navigate bool) error {
+	to := storage.Visit{ID: target.ID, Name: target.Name, Created: target.Created}
+	if client.Inside() {
+		var from storage.Visit
+		if current, err := client.CurrentSession(); err == nil {
+			from = storage.Visit{ID: current.ID, Name: current.Name, Created: current.Created}
 		}
-		
-		if !sessions[nextIndex].Deleted {
-			return nextIndex
+		name := currentClientName(client)
+		if err := client.SwitchTo(tmux.Target(target.ID, target.Name)); err != nil {
+			return err
 		}
+		recordSwitch(opts, name, from, to, navigate)
+		return nil
 	}
-	
-	// No active session found
-	return -1
+
+	// Attaching blocks until the user detaches, so record it first
+	recordSwitch(opts, "", storage.Visit{}, to, navigate)
+
+	// Don't hold up other commands while attached
+	unlockSessions()
+	if err := client.Attach(tmux.Target(target.ID, target.Name)); err != nil {
+		lockSessions(opts)
+		return err
+	}
+	return nil
 }
 
-func findPrevActiveSession(sessions []storage.SessionData, currentIndex int, wrapAround bool) int {
-	if len(sessions) == 0 {
-		return -1
-	}
-	
-	// Determine the search range based on wrapAround setting
-	maxIterations := len(sessions)
-	if !wrapAround {
-		// Only search from current position to beginning
-		maxIterations = currentIndex
-	}
-	
-	// Start from the previous index
-	for i := 1; i <= maxIterations; i++ {
-		var prevIndex int
-		if wrapAround {
-			// Wrap around to the end
-			prevIndex = (currentIndex - i + len(sessions)) % len(sessions)
-		} else {
-			// Don't wrap around
-			prevIndex = currentIndex - i
-			if prevIndex < 0 {
-				break
+// recordSwitch adds a switch to the MRU log and, for a new navigation, to
+// the navigation stack of the named client. Failures are reported but not
+// fatal as the switch itself succeeded.
+func recordSwitch(opts globalOptions, clientName string, from, to storage.Visit, navigate bool) {
+	if err := storage.RecordSwitch(opts.serverKey(), from, to); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record session switch: %v\n", err)
+	}
+	if !navigate {
+		return
+	}
+	if err := storage.PushNavigation(opts.serverKey(), clientName, from, to); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record navigation: %v\n", err)
+	}
+}
+
+// currentClientName names the tmux client rolo runs in, "" outside tmux or
+// when it can't be told
+func currentClientName(client tmux.Client) string {
+	if !client.Inside() {
+		return ""
+	}
+	name, err := client.CurrentClient()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// skipRules returns a function reporting whether next and prev pass over an
+// entry under the skip settings, judged against live tmux metadata. Rules
+// needing metadata tmux can't provide are left out with a warning.
+func skipRules(client tmux.Client, config storage.SkipConfig) func(storage.SessionData) bool {
+	facts := make(map[string]*storage.SkipFacts)
+	ids := make(map[string]string)
+	if config.AttachedElsewhere || config.Idle {
+		live, err := client.ListSessions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to get tmux sessions for skip rules: %v\n", err)
+		}
+
+		// The client rolo runs in doesn't count as elsewhere
+		currentID := ""
+		if client.Inside() {
+			if current, err := client.CurrentSession(); err == nil {
+				currentID = current.ID
 			}
 		}
-		
-		if !sessions[prevIndex].Deleted {
-			return prevIndex
+		for _, session := range live {
+			attached := session.Attached
+			if session.ID == currentID {
+				attached--
+			}
+			facts[session.ID] = &storage.SkipFacts{AttachedElsewhere: max(attached, 0)}
+			ids[session.Name] = session.ID
 		}
 	}
-	
-	// No active session found
-	return -1
+	if config.Idle && len(facts) > 0 {
+		panes, err := client.ListPanes()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to get tmux panes for skip rules: %v\n", err)
+		}
+		for _, pane := range panes {
+			if f, ok := facts[pane.SessionID]; ok {
+				f.Commands = append(f.Commands, pane.Command)
+			}
+		}
+	}
+
+	return func(session storage.SessionData) bool {
+		id := session.ID
+		if _, ok := facts[id]; !ok {
+			id = ids[session.Name]
+		}
+		return config.Skips(session, facts[id])
+	}
+}
+
+// stepFlags are the flags of next and prev
+type stepFlags struct {
+	// group keeps to the current session's group
+	group bool
+	// strategy overrides the strategy set in config
+	strategy string
+}
+
+// parseStepFlags parses the arguments of next and prev
+func parseStepFlags(command string, args []string) stepFlags {
+	var flags stepFlags
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--group", "-g":
+			flags.group = true
+		case "--strategy", "-s":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			flags.strategy = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "Usage: rolo %s [--group] [--strategy <%s>]\n", command, strings.Join(navigation.StrategyNames(), "|"))
+			os.Exit(1)
+		}
+	}
+	return flags
+}
+
+// currentGroup returns the group of the entry at currentIndex, exiting if
+// the current session isn't in the list
+func currentGroup(sessions []storage.SessionData, currentIndex int) string {
+	if currentIndex < 0 || currentIndex >= len(sessions) {
+		fmt.Fprintf(os.Stderr, "Error: --group needs the current session to be in the list\n")
+		os.Exit(1)
+	}
+	return sessions[currentIndex].Group
+}
+
+// withinGroup extends skip to pass over every entry outside group
+func withinGroup(skip func(storage.SessionData) bool, group string) func(storage.SessionData) bool {
+	return func(session storage.SessionData) bool {
+		return session.Group != group || skip(session)
+	}
 }
 
-func handleNext() {
+// handleStep switches to the next session, or the previous one for
+// navigation.Backward, in the order of the chosen strategy. Entries whose
+// session no longer exists are marked missing and passed over.
+func handleStep(client tmux.Client, opts globalOptions, args []string, direction navigation.Direction) {
+	command := "next"
+	if direction == navigation.Backward {
+		command = "prev"
+	}
+	flags := parseStepFlags(command, args)
+
+	lockSessions(opts)
+	defer unlockSessions()
+
 	// Load config
 	config, err := storage.LoadConfig()
 	if err != nil {
@@ -139,15 +672,18 @@ func handleNext() {
 		os.Exit(1)
 	}
 
-	// Get current session
-	currentSession, err := tmux.GetCurrentSession()
+	strategyName := flags.strategy
+	if strategyName == "" {
+		strategyName = config.Strategy
+	}
+	strategy, err := navigation.ParseStrategy(strategyName)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current session: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Load ordered sessions
-	sessions, err := storage.LoadSessionsData()
+	sessions, err := storage.LoadSessionsData(opts.listKey())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
 		os.Exit(1)
@@ -158,75 +694,94 @@ func handleNext() {
 		os.Exit(1)
 	}
 
-	// Find current session index
-	currentIndex := findSessionIndex(sessions, currentSession)
-	if currentIndex == -1 {
-		// Current session not in list, attach to first active session
-		currentIndex = -1 // Start from beginning
+	sessions = reconcileSessions(client, opts, sessions)
+
+	// Find current session index, outside tmux there is no current session
+	// and the navigator starts from either end
+	currentIndex := -1
+	if client.Inside() {
+		currentSession, err := client.CurrentSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting current session: %v\n", err)
+			os.Exit(1)
+		}
+		currentIndex = findSessionIndex(sessions, currentSession)
 	}
 
-	// Try to find next active session, skipping ones that don't exist
-	tried := 0
-	maxAttempts := len(sessions)
-	
-	for tried < maxAttempts {
-		// Find next active (non-deleted) session
-		nextIndex := findNextActiveSession(sessions, currentIndex, config.WrapAround)
-		if nextIndex == -1 {
-			if !config.WrapAround {
-				// Fail silently when at the end and not wrapping
-				return
-			} else {
-				fmt.Fprintf(os.Stderr, "No active sessions available (all are deleted)\n")
-				os.Exit(1)
-			}
+	navigator := navigation.New(client, opts.serverKey(), strategy)
+	navigator.WrapAround = config.WrapAround
+	navigator.Skip = skipRules(client, config.Skip)
+	groupName := ""
+	if flags.group {
+		groupName = currentGroup(sessions, currentIndex)
+		navigator.Skip = withinGroup(navigator.Skip, groupName)
+	}
+
+	// A run of steps goes on through the order it started with, see
+	// navigation.Navigator.Cycle
+	clientName := currentClientName(client)
+	nav, err := storage.LoadNavigation(opts.serverKey(), clientName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if nav.Cycle != nil && nav.Cycle.List == opts.list {
+		navigator.Cycle = nav.Cycle
+	}
+
+	_, err = navigator.Step(sessions, currentIndex, direction, func(index int) bool {
+		return switchToEntry(client, opts, sessions, index)
+	})
+	if err == nil && (navigator.Cycle != nil || (nav != nil && nav.Cycle != nil)) {
+		if navigator.Cycle != nil {
+			navigator.Cycle.List = opts.list
 		}
-		
-		nextSession := sessions[nextIndex].Name
-		
-		// Try to switch to next session
-		if err := tmux.SwitchToSession(nextSession); err != nil {
-			// Log the error and mark session as deleted
-			fmt.Fprintf(os.Stderr, "Warning: Session '%s' doesn't exist, skipping: %v\n", nextSession, err)
-			sessions[nextIndex].Deleted = true
-			
-			// Save the updated state
-			if saveErr := storage.SaveSessionsData(sessions); saveErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to save updated session state: %v\n", saveErr)
-			}
-			
-			// Try the next one
-			currentIndex = nextIndex
-			tried++
-			continue
+		if err := storage.SaveCycle(opts.serverKey(), clientName, navigator.Cycle); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to record the run of steps: %v\n", err)
 		}
-		
-		// Success!
-		return
 	}
-	
-	// If we've tried all sessions and none worked
-	fmt.Fprintf(os.Stderr, "Error: No valid sessions found\n")
-	os.Exit(1)
+	switch {
+	case err == nil, errors.Is(err, navigation.ErrEnd):
+		// Fail silently at either end when not wrapping
+	case errors.Is(err, navigation.ErrNoActive):
+		fmt.Fprintf(os.Stderr, "No active sessions available (all are hidden, missing or archived)\n")
+		os.Exit(1)
+	case errors.Is(err, navigation.ErrSkipped) && flags.group:
+		fmt.Fprintf(os.Stderr, "No sessions to switch to in group '%s'\n", storage.GroupLabel(groupName))
+		os.Exit(1)
+	case errors.Is(err, navigation.ErrSkipped):
+		fmt.Fprintf(os.Stderr, "No sessions to switch to, the others are skipped by the skip settings\n")
+		os.Exit(1)
+	case errors.Is(err, navigation.ErrNoValid):
+		fmt.Fprintf(os.Stderr, "Error: No valid sessions found\n")
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
-func handlePrev() {
-	// Load config
-	config, err := storage.LoadConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+// handleGroupJump switches to the first session of the next group, or of
+// the previous one for a negative direction, passing over groups without a
+// session to switch to. Groups are ordered by their first entry.
+func handleGroupJump(client tmux.Client, opts globalOptions, args []string, direction int) {
+	command := "next-group"
+	if direction < 0 {
+		command = "prev-group"
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Usage: rolo %s\n", command)
 		os.Exit(1)
 	}
 
-	// Get current session
-	currentSession, err := tmux.GetCurrentSession()
+	lockSessions(opts)
+	defer unlockSessions()
+
+	config, err := storage.LoadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current session: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Load ordered sessions
-	sessions, err := storage.LoadSessionsData()
+	sessions, err := storage.LoadSessionsData(opts.listKey())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
 		os.Exit(1)
@@ -237,104 +792,1405 @@ func handlePrev() {
 		os.Exit(1)
 	}
 
-	// Find current session index
-	currentIndex := findSessionIndex(sessions, currentSession)
-	if currentIndex == -1 {
-		// Current session not in list, attach to first active session
-		currentIndex = -1 // Start from beginning
+	sessions = reconcileSessions(client, opts, sessions)
+
+	// Outside tmux, or from a session that isn't listed, start before the
+	// first group or after the last one
+	groups := storage.GroupNames(sessions)
+	current := -1
+	if direction < 0 {
+		current = len(groups)
+	}
+	if client.Inside() {
+		currentSession, err := client.CurrentSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting current session: %v\n", err)
+			os.Exit(1)
+		}
+		if index := findSessionIndex(sessions, currentSession); index != -1 {
+			for i, group := range groups {
+				if group == sessions[index].Group {
+					current = i
+				}
+			}
+		}
 	}
 
-	// Try to find previous active session, skipping ones that don't exist
-	tried := 0
-	maxAttempts := len(sessions)
-	
-	for tried < maxAttempts {
-		// Find previous active (non-deleted) session
-		prevIndex := findPrevActiveSession(sessions, currentIndex, config.WrapAround)
-		if prevIndex == -1 {
-			if !config.WrapAround {
-				// Fail silently when at the beginning and not wrapping
+	skip := skipRules(client, config.Skip)
+	for step := 1; step <= len(groups); step++ {
+		i := current + step*direction
+		if config.WrapAround {
+			i = (i%len(groups) + len(groups)) % len(groups)
+		}
+		if i < 0 || i >= len(groups) {
+			// Fail silently at either end when not wrapping, like next and prev
+			return
+		}
+		if i == current {
+			break
+		}
+
+		for index, session := range sessions {
+			if session.Group != groups[i] || !session.Active() || skip(session) {
+				continue
+			}
+			if switchToEntry(client, opts, sessions, index) {
 				return
-			} else {
-				fmt.Fprintf(os.Stderr, "No active sessions available (all are deleted)\n")
-				os.Exit(1)
 			}
 		}
-		
-		prevSession := sessions[prevIndex].Name
-		
-		// Try to switch to previous session
-		if err := tmux.SwitchToSession(prevSession); err != nil {
-			// Log the error and mark session as deleted
-			fmt.Fprintf(os.Stderr, "Warning: Session '%s' doesn't exist, skipping: %v\n", prevSession, err)
-			sessions[prevIndex].Deleted = true
-			
-			// Save the updated state
-			if saveErr := storage.SaveSessionsData(sessions); saveErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to save updated session state: %v\n", saveErr)
-			}
-			
-			// Try the next one
-			currentIndex = prevIndex
-			tried++
+	}
+
+	fmt.Fprintf(os.Stderr, "No other group has a session to switch to\n")
+	os.Exit(1)
+}
+
+// findSlotIndex returns the index of the nth (1-based) active session
+func findSlotIndex(sessions []storage.SessionData, slot int) int {
+	for i, session := range sessions {
+		if !session.Active() {
 			continue
 		}
-		
-		// Success!
-		return
+		slot--
+		if slot == 0 {
+			return i
+		}
 	}
-	
-	// If we've tried all sessions and none worked
-	fmt.Fprintf(os.Stderr, "Error: No valid sessions found\n")
-	os.Exit(1)
+	return -1
 }
 
-func runInteractiveMode() {
-	// Load sessions from storage
-	sessions, err := storage.LoadSessionsData()
+func handleGoto(client tmux.Client, opts globalOptions, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: rolo goto <n|name>\n")
+		os.Exit(1)
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	// Load ordered sessions
+	sessions, err := storage.LoadSessionsData(opts.listKey())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
 		os.Exit(1)
 	}
 
-	// If no sessions exist, provide a helpful message
 	if len(sessions) == 0 {
-		sessions = []storage.SessionData{
-			{Name: "No sessions found", Deleted: false},
-			{Name: "Run 'rolo populate' to fetch tmux sessions", Deleted: false},
-		}
-	}
-
-	// Run the TUI
-	if err := tui.Run(sessions, saveOrder); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "No sessions configured. Run 'rolo populate' first.\n")
 		os.Exit(1)
 	}
-}
 
-func main() {
+	sessions = reconcileSessions(client, opts, sessions)
+
+	// A leading "=" forces a name lookup, for sessions with numeric names
+	slot, err := strconv.Atoi(args[0])
+	if err != nil || strings.HasPrefix(args[0], "=") {
+		name := strings.TrimPrefix(args[0], "=")
+		index := findSessionIndex(sessions, tmux.Session{Name: name})
+		if index == -1 {
+			fmt.Fprintf(os.Stderr, "Error: Session '%s' is not in the list\n", name)
+			os.Exit(1)
+		}
+		if !switchToEntry(client, opts, sessions, index) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if slot < 1 {
+		fmt.Fprintf(os.Stderr, "Error: Slot must be 1 or greater, got %d\n", slot)
+		os.Exit(1)
+	}
+
+	// Missing sessions are marked missing, which shifts later entries into
+	// the slot, so keep trying until a switch succeeds or the slot is empty
+	for {
+		index := findSlotIndex(sessions, slot)
+		if index == -1 {
+			fmt.Fprintf(os.Stderr, "Error: No session in slot %d\n", slot)
+			os.Exit(1)
+		}
+		if switchToEntry(client, opts, sessions, index) {
+			return
+		}
+	}
+}
+
+// handleLast switches to the most recently visited session other than the
+// current one, as recorded by every switch rolo makes. Sessions that no
+// longer exist are skipped and forgotten.
+func handleLast(client tmux.Client, opts globalOptions) {
+	lockSessions(opts)
+	defer unlockSessions()
+
+	live, err := client.ListSessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting tmux sessions: %v\n", err)
+		os.Exit(1)
+	}
+	names := navigation.LiveSessions(live)
+
+	var current storage.Visit
+	if client.Inside() {
+		if session, err := client.CurrentSession(); err == nil {
+			current = storage.Visit{ID: session.ID, Name: session.Name, Created: session.Created}
+		}
+	}
+
+	// Each failed attempt removes a session from names, so this ends
+	for {
+		previous, err := storage.PreviousSession(opts.serverKey(), current, names)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		target := tmux.Session{ID: previous.ID, Name: previous.Name, Created: previous.Created}
+		err = switchSession(client, opts, target, true)
+		if err == nil {
+			return
+		}
+		if exists, _ := client.HasSession(tmux.Target(target.ID, target.Name)); exists {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: Session '%s' doesn't exist, skipping: %v\n", target.Name, err)
+		delete(names, target.ID)
+	}
+}
+
+// handleNavigate moves back (negative steps) or forward through the
+// calling client's navigation stack, skipping and forgetting sessions that
+// no longer exist
+func handleNavigate(client tmux.Client, opts globalOptions, args []string, direction int) {
+	command := "back"
+	if direction > 0 {
+		command = "forward"
+	}
+
+	steps := 1
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: rolo %s [n]\n", command)
+		os.Exit(1)
+	}
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Error: Expected a positive number of steps, got '%s'\n", args[0])
+			os.Exit(1)
+		}
+		steps = n
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	live, err := client.ListSessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting tmux sessions: %v\n", err)
+		os.Exit(1)
+	}
+	names := navigation.LiveSessions(live)
+
+	var current storage.Visit
+	if client.Inside() {
+		if session, err := client.CurrentSession(); err == nil {
+			current = storage.Visit{ID: session.ID, Name: session.Name, Created: session.Created}
+		}
+	}
+	clientName := currentClientName(client)
+
+	// Each failed attempt removes a session from names, so this ends
+	for {
+		visit, err := storage.MoveNavigation(opts.serverKey(), clientName, current, steps*direction, names)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		target := tmux.Session{ID: visit.ID, Name: visit.Name, Created: visit.Created}
+		err = switchSession(client, opts, target, false)
+		if err == nil {
+			return
+		}
+		if exists, _ := client.HasSession(tmux.Target(target.ID, target.Name)); exists {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: Session '%s' doesn't exist, skipping: %v\n", target.Name, err)
+		delete(names, target.ID)
+
+		// Dropping the visit moves the stack's position to the visit before
+		// it: that is the next one back, the next one forward is one further
+		current, steps = storage.Visit{}, 0
+		if direction > 0 {
+			steps = 1
+		}
+	}
+}
+
+// findHandoffIndex picks the session that clients of the session at index
+// move to when it is killed: the next session in order, or the previous one
+// when at the end of the list without WrapAround. Entries that no longer
+// exist in tmux are marked missing along the way. Returns -1 if there is
+// nowhere to go.
+func findHandoffIndex(client tmux.Client, sessions []storage.SessionData, index int, wrapAround bool) int {
+	navigator := navigation.New(client, "", navigation.Stored{})
+	navigator.WrapAround = wrapAround
+	for _, direction := range []navigation.Direction{navigation.Forward, navigation.Backward} {
+		// The stored order can't fail
+		candidates, _ := navigator.Candidates(sessions, index, direction)
+		for _, candidate := range candidates {
+			if candidate == index {
+				break
+			}
+			if exists, err := client.HasSession(tmux.Target(sessions[candidate].ID, sessions[candidate].Name)); err == nil && exists {
+				return candidate
+			}
+			sessions[candidate].SetState(storage.StatusMissing, time.Now())
+		}
+	}
+	return -1
+}
+
+// killEntry kills the session at index after switching every client attached
+// to it to the handoff session, then removes the entry from the list, or
+// archives it when tombstone is set. The updated list is returned and
+// saved before the kill, since rolo itself may be running in that session.
+func killEntry(client tmux.Client, opts globalOptions, sessions []storage.SessionData, index int, tombstone bool) ([]storage.SessionData, error) {
+	config, err := storage.LoadConfig()
+	if err != nil {
+		return sessions, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	victim := sessions[index]
+	target := tmux.Target(victim.ID, victim.Name)
+
+	// Work on a copy so a failed kill leaves the saved list untouched
+	updated := append([]storage.SessionData{}, sessions...)
+
+	clients, err := client.ListClients()
+	if err != nil {
+		return sessions, err
+	}
+	handoff := -1
+	for _, attached := range clients {
+		if attached.SessionID != victim.ID && attached.SessionName != victim.Name {
+			continue
+		}
+		if handoff == -1 {
+			handoff = findHandoffIndex(client, updated, index, config.WrapAround)
+			if handoff == -1 {
+				// Nothing to hand off to, tmux will detach the clients
+				break
+			}
+		}
+		next := updated[handoff]
+		if err := client.SwitchClient(attached.Name, tmux.Target(next.ID, next.Name)); err != nil {
+			return sessions, err
+		}
+	}
+
+	if tombstone {
+		updated[index].SetState(storage.StatusArchived, time.Now())
+	} else {
+		updated = append(updated[:index], updated[index+1:]...)
+	}
+	if err := storage.SaveSessionsData(opts.listKey(), updated, storage.CauseKill); err != nil {
+		return sessions, fmt.Errorf("failed to save sessions: %w", err)
+	}
+
+	if err := client.KillSession(target); err != nil {
+		// Put the entry back, the session is still running
+		if saveErr := storage.SaveSessionsData(opts.listKey(), sessions, storage.CauseKill); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to restore session list: %v\n", saveErr)
+		}
+		return sessions, err
+	}
+
+	return updated, nil
+}
+
+func handleKill(client tmux.Client, opts globalOptions, args []string) {
+	tombstone := false
+	name := ""
+	for _, arg := range args {
+		switch arg {
+		case "--tombstone", "-t":
+			tombstone = true
+		default:
+			if name != "" || strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(os.Stderr, "Usage: rolo kill [--tombstone] [name]\n")
+				os.Exit(1)
+			}
+			name = arg
+		}
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessions = reconcileSessions(client, opts, sessions)
+
+	// Default to the current session
+	var victim tmux.Session
+	if name == "" {
+		victim, err = client.CurrentSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting current session: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		victim = tmux.Session{Name: name}
+	}
+
+	index := findSessionIndex(sessions, victim)
+	if index == -1 {
+		// Not in the list, so there is no order to respect
+		if err := client.KillSession(tmux.Target(victim.ID, victim.Name)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if _, err := killEntry(client, opts, sessions, index, tombstone); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleStatus(client tmux.Client, opts globalOptions, args []string) {
+	config, err := storage.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var current tmux.Session
+	hasCurrent := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--current", "-c":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			current = tmux.Session{Name: args[i]}
+			hasCurrent = true
+		case "--max-width", "-w":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			maxWidth, err := strconv.Atoi(args[i])
+			if err != nil || maxWidth < 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid max width: %s\n", args[i])
+				os.Exit(1)
+			}
+			config.Status.MaxWidth = maxWidth
+		case "--separator", "-s":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			config.Status.Separator = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag for status: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	// Asking tmux costs a process, so prefer the name passed in from #{session_name}
+	if !hasCurrent && client.Inside() {
+		current, err = client.CurrentSession()
+		if err != nil {
+			current = tmux.Session{}
+		}
+	}
+
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(status.Format(sessions, current, config.Status))
+}
+
+func handleBindings(args []string) {
+	noPrefix := false
+	modifier := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--no-prefix", "-n":
+			noPrefix = true
+		case "--modifier", "-m":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			modifier = strings.TrimSuffix(args[i], "-")
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag for bindings: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	bind := "bind-key"
+	if noPrefix {
+		bind = "bind-key -n"
+	}
+
+	fmt.Println("# rolo slots, add to ~/.tmux.conf")
+	for slot := 1; slot <= 9; slot++ {
+		key := strconv.Itoa(slot)
+		if modifier != "" {
+			key = modifier + "-" + key
+		}
+		fmt.Printf("%s %s run-shell \"rolo goto %d\"\n", bind, key, slot)
+	}
+}
+
+// handleUndo moves the list back (or forward, for redo) through its history
+func handleUndo(opts globalOptions, args []string, redo bool) {
+	steps := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Error: invalid number of steps: %s\n", args[0])
+			os.Exit(1)
+		}
+		steps = n
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	// Report what is being undone before the position moves past it
+	before, err := storage.LoadHistory(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	undone, _ := before.Current()
+
+	var history *storage.History
+	if redo {
+		history, err = storage.Redo(opts.listKey(), steps)
+	} else {
+		history, err = storage.Undo(opts.listKey(), steps)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	restored, _ := history.Current()
+	if redo {
+		fmt.Printf("Redid %s, ", restored.Cause)
+	} else {
+		fmt.Printf("Undid %s, ", undone.Cause)
+	}
+	fmt.Printf("restored the order saved %s:\n", formatAgo(time.Since(restored.Time)))
+	for i, session := range restored.Sessions {
+		state := ""
+		if !session.Active() {
+			state = " (" + string(session.State()) + ")"
+		}
+		fmt.Printf("  %d. %s%s\n", i+1, session.Name, state)
+	}
+}
+
+// formatAgo renders how long ago something happened, e.g. "5m ago"
+func formatAgo(d time.Duration) string {
+	age := tmux.FormatAge(d)
+	if age == "now" {
+		return "just now"
+	}
+	return age + " ago"
+}
+
+func handleHistory(opts globalOptions) {
+	history, err := storage.LoadHistory(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(history.Snapshots) == 0 {
+		fmt.Println("No history yet")
+		return
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t#\tSAVED\tCAUSE\tORDER")
+	// Newest first, * marks the snapshot the list is at
+	for i := len(history.Snapshots) - 1; i >= 0; i-- {
+		snapshot := history.Snapshots[i]
+		marker := ""
+		if i == history.Position {
+			marker = "*"
+		}
+
+		names := make([]string, 0, len(snapshot.Sessions))
+		for _, session := range snapshot.Sessions {
+			if session.Active() {
+				names = append(names, session.Name)
+			}
+		}
+		order := strings.Join(names, " ")
+		if runes := []rune(order); len(runes) > 60 {
+			order = string(runes[:59]) + "…"
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", marker, i+1, formatAgo(now.Sub(snapshot.Time)), snapshot.Cause, order)
+	}
+	w.Flush()
+}
+
+// findEntry looks up a list entry by slot number, name or alias, with a
+// leading "=" forcing a name lookup as with goto. Returns -1 if not found.
+func findEntry(sessions []storage.SessionData, arg string) int {
+	if slot, err := strconv.Atoi(arg); err == nil && !strings.HasPrefix(arg, "=") {
+		return findSlotIndex(sessions, slot)
+	}
+
+	name := strings.TrimPrefix(arg, "=")
+	if index := findSessionIndex(sessions, tmux.Session{Name: name}); index != -1 {
+		return index
+	}
+	for i, session := range sessions {
+		if session.Alias == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func handleMeta(opts globalOptions, args []string) {
+	if len(args) < 2 || (args[0] != "show" && len(args) < 3) {
+		fmt.Fprintf(os.Stderr, "Usage: rolo meta set <n|name> <field>=<value>...\n")
+		fmt.Fprintf(os.Stderr, "       rolo meta unset <n|name> <field>...\n")
+		fmt.Fprintf(os.Stderr, "       rolo meta show <n|name>\n")
+		fmt.Fprintf(os.Stderr, "Fields: %s\n", strings.Join(storage.MetaFields, ", "))
+		os.Exit(1)
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	index := findEntry(sessions, args[1])
+	if index == -1 {
+		fmt.Fprintf(os.Stderr, "Error: Session '%s' is not in the list\n", args[1])
+		os.Exit(1)
+	}
+	session := &sessions[index]
+	name := session.Name
+
+	// Entries of a group are kept together
+	regroup := false
+
+	switch args[0] {
+	case "show":
+		fmt.Printf("name: %s\n", session.Name)
+		for _, field := range storage.MetaFields {
+			if value := session.GetMeta(field); value != "" {
+				fmt.Printf("%s: %s\n", field, value)
+			}
+		}
+		return
+
+	case "set":
+		for _, assignment := range args[2:] {
+			field, value, err := storage.ParseMetaAssignment(assignment)
+			if err == nil {
+				err = session.SetMeta(field, value)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			regroup = regroup || field == "group"
+		}
+
+	case "unset":
+		for _, field := range args[2:] {
+			if err := session.SetMeta(field, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			regroup = regroup || field == "group"
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown meta command: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	if regroup {
+		sessions = storage.GroupSessions(sessions)
+	}
+	if err := storage.SaveSessionsData(opts.listKey(), sessions, storage.CauseMeta); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving sessions: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated '%s'\n", name)
+}
+
+func handleExport(opts globalOptions, args []string) {
+	format := exchange.JSON
+	output := ""
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if i+1 >= len(args) {
+			fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", flag)
+			os.Exit(1)
+		}
+		i++
+		switch flag {
+		case "--format", "-f":
+			var err error
+			if format, err = exchange.ParseFormat(args[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--output", "-o":
+			output = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag for export: %s\n", flag)
+			os.Exit(1)
+		}
+	}
+
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	if err := exchange.Export(&buf, format, sessions); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" || output == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d session(s) to %s\n", len(sessions), output)
+}
+
+func handleImport(opts globalOptions, args []string) {
+	var format exchange.Format
+	source := ""
+	replace := false
+	dryRun := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format", "-f":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: flag %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			var err error
+			if format, err = exchange.ParseFormat(args[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "--replace", "-r":
+			replace = true
+		case "--dry-run", "-n":
+			dryRun = true
+		default:
+			if source != "" || (strings.HasPrefix(args[i], "-") && args[i] != "-") {
+				fmt.Fprintf(os.Stderr, "Unknown flag for import: %s\n", args[i])
+				os.Exit(1)
+			}
+			source = args[i]
+		}
+	}
+	if source == "" {
+		fmt.Fprintf(os.Stderr, "Usage: rolo import [--format <format>] [--replace] [--dry-run] <file|->\n")
+		os.Exit(1)
+	}
+
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", source, err)
+		os.Exit(1)
+	}
+	if format == "" {
+		format = exchange.Detect(source, data)
+	}
+
+	imported, err := exchange.Import(data, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	current, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	var updated []storage.SessionData
+	if replace {
+		updated = exchange.Replace(current, imported)
+	} else {
+		updated = exchange.Merge(current, imported)
+	}
+
+	for _, line := range exchange.Diff(current, updated) {
+		fmt.Println(line)
+	}
+	if dryRun {
+		fmt.Printf("Dry run: %d session(s) read as %s, nothing saved\n", len(imported), format)
+		return
+	}
+
+	if err := storage.SaveSessionsData(opts.listKey(), updated, storage.CauseImport); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving sessions: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d session(s) read as %s, the list now has %d\n", len(imported), format, len(updated))
+}
+
+func handleLists(opts globalOptions) {
+	names, err := storage.ListNames()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, name := range names {
+		marker := "  "
+		if name == opts.list {
+			marker = "* "
+		}
+		fmt.Println(marker + name)
+	}
+}
+
+func handleListCommand(opts globalOptions, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: rolo list %s <name>\n", args[0])
+		os.Exit(1)
+	}
+	name := args[1]
+
+	switch args[0] {
+	case "use":
+		exists, err := storage.ListExists(name)
+		if err == nil && !exists {
+			err = fmt.Errorf("list '%s' doesn't exist, create it with 'rolo list new %s'", name, name)
+		}
+		if err == nil {
+			err = storage.SetActiveList(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Using list '%s'\n", name)
+
+	case "new":
+		copyActive := false
+		for _, arg := range args[2:] {
+			switch arg {
+			case "--copy", "-c":
+				copyActive = true
+			default:
+				fmt.Fprintf(os.Stderr, "Unknown flag for list new: %s\n", arg)
+				os.Exit(1)
+			}
+		}
+
+		var sessions []storage.SessionData
+		if copyActive {
+			var err error
+			sessions, err = storage.LoadSessionsData(opts.listKey())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if err := storage.ValidateListName(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// No lock, nothing else uses the list before it exists
+		key := storage.ListKey{Server: opts.serverKey(), List: name}
+		if err := storage.CreateList(key, sessions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created list '%s' with %d session(s), switch to it with 'rolo list use %s'\n", name, len(sessions), name)
+
+	case "rm", "remove":
+		if err := storage.RemoveList(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Don't leave the active list pointing at a list that is gone
+		if err := storage.ForgetActiveList(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to reset the active list: %v\n", err)
+		}
+		fmt.Printf("Removed list '%s'\n", name)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown list command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleMigrate(args []string) {
+	check := false
+	for _, arg := range args {
+		switch arg {
+		case "--check", "-n":
+			check = true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag for migrate: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	// MigrateAll locks each list itself, taking a list's lock twice in one
+	// process would block forever
+	var reports []storage.MigrationReport
+	var err error
+	if check {
+		reports, err = storage.CheckMigrations()
+	} else {
+		reports, err = storage.MigrateAll()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pending := 0
+	for _, report := range reports {
+		if !report.NeedsMigration() {
+			fmt.Printf("%s: up to date (version %d, %d sessions)\n", report.Target, report.To, report.Sessions)
+			continue
+		}
+		pending++
+
+		verb := "migrated"
+		if check {
+			verb = "would migrate"
+		}
+		fmt.Printf("%s: %s from version %d to %d (%d sessions)\n", report.Source, verb, report.From, report.To, report.Sessions)
+		for _, step := range report.Steps {
+			fmt.Printf("  %s\n", step)
+		}
+		if report.Source != report.Target {
+			fmt.Printf("  move to %s\n", report.Target)
+		}
+		fmt.Printf("  keep original as %s\n", report.Backup)
+	}
+
+	if len(reports) == 0 {
+		fmt.Println("No session lists found")
+	}
+	// Like a dry run of other tools, --check fails while work is pending
+	if check && pending > 0 {
+		os.Exit(1)
+	}
+}
+
+func handleDoctor(opts globalOptions, args []string) {
+	fix := false
+	for _, arg := range args {
+		switch arg {
+		case "--fix":
+			fix = true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag for doctor: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	lockSessions(opts)
+	defer unlockSessions()
+
+	storage.QuietIssues()
+	path, err := storage.GetListPath(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sessions, err := storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !fix {
+		issues := storage.CheckSessions(sessions)
+		if len(issues) == 0 {
+			fmt.Printf("%s: ok (%d sessions)\n", path, len(sessions))
+			return
+		}
+		fmt.Printf("%s:\n", path)
+		for _, issue := range issues {
+			fmt.Printf("  %s\n", issue)
+		}
+		fmt.Printf("Run 'rolo doctor --fix' to keep the first entry of each session\n")
+		os.Exit(1)
+	}
+
+	repaired, issues := storage.RepairSessions(sessions)
+	if len(issues) == 0 {
+		fmt.Printf("%s: ok (%d sessions), nothing to fix\n", path, len(sessions))
+		return
+	}
+	if err := storage.SaveSessionsData(opts.listKey(), repaired, storage.CauseRepair); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving sessions: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s:\n", path)
+	for _, issue := range issues {
+		fmt.Printf("  removed %s\n", issue)
+	}
+	fmt.Printf("%d sessions left, 'rolo undo' restores the list as it was\n", len(repaired))
+}
+
+func handleConfig(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: rolo config get [key]\n")
+		fmt.Fprintf(os.Stderr, "       rolo config set <key> <value>\n")
+		fmt.Fprintf(os.Stderr, "       rolo config edit|validate|schema\n")
+		fmt.Fprintf(os.Stderr, "       rolo config show-defaults [--format json|toml|yaml]\n")
+		fmt.Fprintf(os.Stderr, "Keys: %s\n", strings.Join(storage.ConfigKeys(), ", "))
+		os.Exit(1)
+	}
+
+	path, err := storage.GetConfigSettingsPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "get":
+		config, err := storage.LoadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(args) == 1 {
+			printConfig(path, config)
+			return
+		}
+		value, err := storage.GetConfigValue(config, args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+
+	case "set":
+		if len(args) != 3 {
+			fmt.Fprintf(os.Stderr, "Usage: rolo config set <key> <value>\n")
+			os.Exit(1)
+		}
+		// Only the key changes, the other settings are neither validated nor
+		// rewritten, so this can fix a config rolo refuses to load
+		lock, err := storage.LockConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		value, err := storage.SaveConfigValue(args[1], args[2])
+		lock.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Set %s to %s\n", args[1], value)
+
+	case "edit":
+		editConfig(path)
+
+	case "validate":
+		if !validateConfig(path) {
+			os.Exit(1)
+		}
+
+	case "show-defaults":
+		format := strings.TrimPrefix(filepath.Ext(path), ".")
+		for i := 1; i < len(args); i++ {
+			name, value, hasValue := strings.Cut(args[i], "=")
+			if name != "--format" && name != "-f" {
+				fmt.Fprintf(os.Stderr, "Unknown flag for config show-defaults: %s\n", args[i])
+				os.Exit(1)
+			}
+			if !hasValue {
+				if i+1 >= len(args) {
+					fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", name)
+					os.Exit(1)
+				}
+				i++
+				value = args[i]
+			}
+			format = value
+		}
+		printConfig("config."+format, storage.DefaultConfig())
+
+	case "schema":
+		schema, err := storage.ConfigSchema()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(schema)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// printConfig writes config to stdout in the format of path's extension
+func printConfig(path string, config *storage.Config) {
+	store, err := storage.StoreFor(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := store.Marshal(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(bytes.TrimRight(data, "\n"))
+	fmt.Println()
+}
+
+// validateConfig prints the problems of the config file at path and
+// reports whether there were none
+func validateConfig(path string) bool {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s doesn't exist, the defaults are used\n", path)
+		return true
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	problems, err := storage.ValidateConfig(path, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return false
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%v\n", problem)
+	}
+	if len(problems) > 0 {
+		return false
+	}
+	fmt.Printf("%s: ok\n", path)
+	return true
+}
+
+// editConfig opens the config file in $VISUAL or $EDITOR, creating it with
+// the defaults first, and validates it afterwards
+func editConfig(path string) {
+	if err := createDefaultConfig(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Through the shell, editors are often set with arguments ("code -w")
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: editor failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !validateConfig(path) {
+		fmt.Fprintf(os.Stderr, "Run 'rolo config edit' again to fix it\n")
+		os.Exit(1)
+	}
+}
+
+// createDefaultConfig writes the defaults to path unless it exists
+func createDefaultConfig(path string) error {
+	lock, err := storage.LockConfig()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	return storage.SaveConfig(storage.DefaultConfig())
+}
+
+func runInteractiveMode(client tmux.Client, opts globalOptions) {
+	// If a list is empty, show a helpful message instead
+	placeholder := false
+	loadSessions := func() ([]storage.SessionData, error) {
+		sessions, err := storage.LoadSessionsData(opts.listKey())
+		if err != nil {
+			return nil, err
+		}
+		placeholder = len(sessions) == 0
+		if placeholder {
+			sessions = []storage.SessionData{
+				{Name: "No sessions found"},
+				{Name: "Run 'rolo populate' to fetch tmux sessions"},
+			}
+		}
+		return sessions, nil
+	}
+
+	sessions, err := loadSessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Run the TUI
+	// The UI doesn't hold the lock while open, only while writing
+	saveOrder := func(sessions []storage.SessionData) error {
+		if placeholder {
+			return nil
+		}
+		lockSessions(opts)
+		defer unlockSessions()
+		return storage.SaveSessionsData(opts.listKey(), sessions, storage.CauseTUISave)
+	}
+
+	killSession := func(victim storage.SessionData) error {
+		if placeholder {
+			return errors.New("the list has no sessions")
+		}
+		lockSessions(opts)
+		defer unlockSessions()
+
+		// Only the kill is saved, the UI keeps its unsaved edits for saveOrder
+		saved, err := storage.LoadSessionsData(opts.listKey())
+		if err != nil {
+			return err
+		}
+		saved = reconcileSessions(client, opts, saved)
+		index := findSessionIndex(saved, tmux.Session{ID: victim.ID, Name: victim.Name})
+		if index == -1 {
+			// Not saved in the list yet, so there is no order to respect
+			return client.KillSession(tmux.Target(victim.ID, victim.Name))
+		}
+		_, err = killEntry(client, opts, saved, index, false)
+		return err
+	}
+
+	names, err := storage.ListNames()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		names = []string{opts.list}
+	}
+	lists := &tui.Lists{
+		Names:  names,
+		Active: opts.list,
+		Switch: func(name string) ([]storage.SessionData, error) {
+			if err := storage.SetActiveList(name); err != nil {
+				return nil, err
+			}
+			opts.list = name
+			return loadSessions()
+		},
+	}
+
+	// The back/forward stack is only shown, a missing one isn't an error
+	navStack, err := storage.LoadNavigation(opts.serverKey(), currentClientName(client))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	selected, err := tui.Run(client, sessions, saveOrder, killSession, lists, navStack)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if selected == nil || placeholder {
+		return
+	}
+
+	// Switch or attach to the session chosen in the UI, using the saved list
+	// so a missing session is marked missing like with next/prev
+	lockSessions(opts)
+	defer unlockSessions()
+
+	sessions, err = storage.LoadSessionsData(opts.listKey())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sessions: %v\n", err)
+		os.Exit(1)
+	}
+	index := findSessionIndex(sessions, tmux.Session{ID: selected.ID, Name: selected.Name})
+	if index == -1 || !switchToEntry(client, opts, sessions, index) {
+		os.Exit(1)
+	}
+}
+
+func main() {
+	opts, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		showUsage()
+		os.Exit(1)
+	}
+
+	if opts.configDir != "" {
+		storage.SetConfigDir(opts.configDir)
+	}
+
+	// Handled before anything reads the config, so a broken one can be fixed
+	if len(args) > 0 && args[0] == "config" {
+		handleConfig(args[1:])
+		return
+	}
+
+	opts.list, err = resolveList(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	client := tmux.NewExecClient(opts.server)
+
 	// Parse command line arguments
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	if len(args) > 0 {
+		switch args[0] {
 		case "populate":
-			handlePopulate()
+			handlePopulate(client, opts)
+			return
+		case "list", "ls":
+			if len(args) > 1 {
+				handleListCommand(opts, args[1:])
+				return
+			}
+			handleList(client, opts)
+			return
+		case "lists":
+			handleLists(opts)
 			return
 		case "next":
-			handleNext()
+			handleStep(client, opts, args[1:], navigation.Forward)
 			return
 		case "prev", "previous":
-			handlePrev()
+			handleStep(client, opts, args[1:], navigation.Backward)
+			return
+		case "next-group":
+			handleGroupJump(client, opts, args[1:], 1)
+			return
+		case "prev-group":
+			handleGroupJump(client, opts, args[1:], -1)
+			return
+		case "goto":
+			handleGoto(client, opts, args[1:])
+			return
+		case "last":
+			handleLast(client, opts)
+			return
+		case "back":
+			handleNavigate(client, opts, args[1:], -1)
+			return
+		case "forward":
+			handleNavigate(client, opts, args[1:], 1)
+			return
+		case "kill":
+			handleKill(client, opts, args[1:])
+			return
+		case "status":
+			handleStatus(client, opts, args[1:])
+			return
+		case "bindings":
+			handleBindings(args[1:])
+			return
+		case "meta":
+			handleMeta(opts, args[1:])
+			return
+		case "export":
+			handleExport(opts, args[1:])
+			return
+		case "import":
+			handleImport(opts, args[1:])
+			return
+		case "undo":
+			handleUndo(opts, args[1:], false)
+			return
+		case "redo":
+			handleUndo(opts, args[1:], true)
+			return
+		case "history":
+			handleHistory(opts)
+			return
+		case "migrate":
+			handleMigrate(args[1:])
+			return
+		case "doctor":
+			handleDoctor(opts, args[1:])
 			return
 		case "help", "-h", "--help":
 			showUsage()
 			return
 		default:
-			fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
 			showUsage()
 			os.Exit(1)
 		}
 	}
 
 	// No arguments - run interactive mode
-	runInteractiveMode()
+	runInteractiveMode(client, opts)
 }
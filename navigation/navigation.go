@@ -0,0 +1,283 @@
+// Package navigation steps through a session list for `rolo next` and
+// `rolo prev`, in an order chosen by a Strategy
+package navigation
+
+import (
+	"errors"
+	"time"
+
+	"rolo/storage"
+	"rolo/tmux"
+)
+
+// Direction is the way a Navigator steps through the list
+type Direction int
+
+const (
+	// Forward steps to the next session, as rolo next does
+	Forward Direction = 1
+	// Backward steps to the previous session, as rolo prev does
+	Backward Direction = -1
+)
+
+var (
+	// ErrEnd is returned when there is no session left in the direction
+	// of travel and the Navigator doesn't wrap around
+	ErrEnd = errors.New("no more sessions in this direction")
+	// ErrNoActive is returned when no entry is active
+	ErrNoActive = errors.New("no active sessions available (all are hidden, missing or archived)")
+	// ErrSkipped is returned when every active entry is passed over by Skip
+	ErrSkipped = errors.New("no sessions to switch to, the others are skipped")
+	// ErrNoValid is returned when none of the sessions could be switched to
+	ErrNoValid = errors.New("no valid sessions found")
+)
+
+// Navigator steps through a session list in the order of its Strategy,
+// passing over entries that aren't active or that Skip reports
+type Navigator struct {
+	// Client is the tmux server whose sessions are navigated
+	Client tmux.Client
+	// Server is the key of the tmux server, whose MRU log orders the mru
+	// and frecency strategies
+	Server   string
+	Strategy Strategy
+	// WrapAround continues from the other end of the order
+	WrapAround bool
+	// Skip passes over entries besides the inactive ones, it may be nil
+	Skip func(storage.SessionData) bool
+	// Clock supplies the current time, defaults to time.Now
+	Clock func() time.Time
+	// Cycle is the run of steps in progress, nil to start a new one. For
+	// strategies whose order changes with every switch, such as mru, a run
+	// keeps stepping through the order it started with while the current
+	// session is where its last step went. Step updates it.
+	Cycle *storage.Cycle
+}
+
+// New returns a Navigator for the given tmux server using strategy
+func New(client tmux.Client, server string, strategy Strategy) *Navigator {
+	return &Navigator{Client: client, Server: server, Strategy: strategy}
+}
+
+// Order returns the positions of the entries in the order of the
+// Navigator's strategy
+func (n *Navigator) Order(entries []storage.SessionData) ([]int, error) {
+	return n.strategy().Order(entries, n.context())
+}
+
+func (n *Navigator) strategy() Strategy {
+	if n.Strategy == nil {
+		return Stored{}
+	}
+	return n.Strategy
+}
+
+// order returns the positions of the entries in the order a step from the
+// entry at current goes through: the order of the Cycle when the step
+// continues it, and the strategy's otherwise
+func (n *Navigator) order(entries []storage.SessionData, current int) ([]int, error) {
+	if n.continues(entries, current) {
+		return cycleOrder(entries, n.Cycle.Order), nil
+	}
+	return n.Order(entries)
+}
+
+// continues reports whether a step from the entry at current continues the
+// Cycle
+func (n *Navigator) continues(entries []storage.SessionData, current int) bool {
+	if n.Cycle == nil || current < 0 || current >= len(entries) {
+		return false
+	}
+	strategy := n.strategy()
+	return changesWithSwitches(strategy) && n.Cycle.Strategy == strategy.Name() && n.Cycle.At.Of(entries[current])
+}
+
+// changesWithSwitches reports whether the switches a Navigator makes change
+// the order of a strategy, which then needs a Cycle to step through it
+func changesWithSwitches(strategy Strategy) bool {
+	switch strategy.(type) {
+	case MostRecentlyUsed, Frecency, LastActivity:
+		return true
+	}
+	return false
+}
+
+// cycleOrder returns the positions of the entries in the order of a
+// Cycle's visits. Entries added since the cycle started follow in list
+// order.
+func cycleOrder(entries []storage.SessionData, visits []storage.Visit) []int {
+	order := make([]int, 0, len(entries))
+	used := make([]bool, len(entries))
+	for _, visit := range visits {
+		for i, entry := range entries {
+			if !used[i] && visit.Of(entry) {
+				order = append(order, i)
+				used[i] = true
+				break
+			}
+		}
+	}
+	for i := range entries {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// Candidates returns the positions of the active entries to try, in order,
+// when stepping in direction from the entry at current. current is -1 when
+// the current session isn't in the list, which starts from either end.
+// Entries reported by Skip are left out. With WrapAround the current entry
+// itself comes last, as the only choice when nothing else is left.
+func (n *Navigator) Candidates(entries []storage.SessionData, current int, direction Direction) ([]int, error) {
+	order, err := n.order(entries, current)
+	if err != nil {
+		return nil, err
+	}
+	candidates, _ := n.candidates(entries, order, current, direction)
+	return candidates, nil
+}
+
+// candidates is Candidates in a given order, also counting the entries
+// Skip left out
+func (n *Navigator) candidates(entries []storage.SessionData, order []int, current int, direction Direction) ([]int, int) {
+	count := len(order)
+	position := -1
+	if direction == Backward {
+		position = count
+	}
+	for i, index := range order {
+		if index == current {
+			position = i
+		}
+	}
+
+	var candidates []int
+	skipped := 0
+	for step := 1; step <= count; step++ {
+		i := position + step*int(direction)
+		if n.WrapAround {
+			i = (i%count + count) % count
+		} else if i < 0 || i >= count {
+			break
+		}
+
+		entry := entries[order[i]]
+		if !entry.Active() {
+			continue
+		}
+		if n.Skip != nil && n.Skip(entry) {
+			skipped++
+			continue
+		}
+		candidates = append(candidates, order[i])
+	}
+	return candidates, skipped
+}
+
+// Step switches to the first candidate in direction from the entry at
+// current, see Candidates, and returns its position. switchTo reports
+// false when an entry's session no longer exists, and the next candidate is
+// tried. Without WrapAround, running out of candidates is ErrEnd.
+func (n *Navigator) Step(entries []storage.SessionData, current int, direction Direction, switchTo func(index int) bool) (int, error) {
+	order, err := n.order(entries, current)
+	if err != nil {
+		return -1, err
+	}
+	candidates, skipped := n.candidates(entries, order, current, direction)
+	for _, index := range candidates {
+		if switchTo(index) {
+			n.track(entries, order, index)
+			return index, nil
+		}
+	}
+
+	switch {
+	case !n.WrapAround:
+		return -1, ErrEnd
+	case skipped > 0:
+		return -1, ErrSkipped
+	case len(candidates) > 0:
+		return -1, ErrNoValid
+	}
+	return -1, ErrNoActive
+}
+
+// track starts or continues the Cycle after a step to the entry at index,
+// in a run through order. Strategies whose order the switches don't change
+// need no cycle.
+func (n *Navigator) track(entries []storage.SessionData, order []int, index int) {
+	strategy := n.strategy()
+	if !changesWithSwitches(strategy) {
+		n.Cycle = nil
+		return
+	}
+
+	visits := make([]storage.Visit, len(order))
+	for i, position := range order {
+		visits[i] = storage.EntryVisit(entries[position])
+	}
+	n.Cycle = &storage.Cycle{Strategy: strategy.Name(), Order: visits, At: storage.EntryVisit(entries[index])}
+}
+
+func (n *Navigator) context() *Context {
+	now := time.Now()
+	if n.Clock != nil {
+		now = n.Clock()
+	}
+	return &Context{client: n.Client, server: n.Server, now: now}
+}
+
+// Context gives strategies what they know besides the list itself. Live
+// sessions and the MRU log are only read from tmux and the state directory
+// when a strategy asks for them.
+type Context struct {
+	client tmux.Client
+	server string
+	now    time.Time
+
+	live []tmux.Session
+	mru  *storage.MRU
+}
+
+// Now returns the time the order is taken at
+func (c *Context) Now() time.Time {
+	return c.now
+}
+
+// Live returns the sessions running on the tmux server
+func (c *Context) Live() ([]tmux.Session, error) {
+	if c.live == nil {
+		if c.client == nil {
+			return nil, errors.New("no tmux server to ask")
+		}
+		live, err := c.client.ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		c.live = live
+	}
+	return c.live, nil
+}
+
+// MRU returns the MRU log of the tmux server
+func (c *Context) MRU() (*storage.MRU, error) {
+	if c.mru == nil {
+		mru, err := storage.LoadMRU(c.server)
+		if err != nil {
+			return nil, err
+		}
+		c.mru = mru
+	}
+	return c.mru, nil
+}
+
+// LiveSessions keys the live sessions by id, as storage reconciles with them
+func LiveSessions(sessions []tmux.Session) map[string]storage.LiveSession {
+	live := make(map[string]storage.LiveSession, len(sessions))
+	for _, session := range sessions {
+		live[session.ID] = storage.LiveSession{Name: session.Name, Created: session.Created}
+	}
+	return live
+}
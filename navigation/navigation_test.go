@@ -0,0 +1,327 @@
+package navigation
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"rolo/storage"
+	"rolo/tmux"
+)
+
+const testServer = "test"
+
+var testNow = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestList points storage at a temp directory and returns a fake tmux
+// server running the sessions, along with a list of them in the same order
+func newTestList(t *testing.T, names ...string) (*tmux.FakeClient, []storage.SessionData) {
+	t.Helper()
+	storage.SetConfigDir(t.TempDir())
+	t.Cleanup(func() { storage.SetConfigDir("") })
+
+	f := tmux.NewFakeClient(names...)
+	entries := make([]storage.SessionData, 0, len(names))
+	for _, session := range f.Sessions() {
+		entries = append(entries, storage.SessionData{ID: session.ID, Name: session.Name, Created: session.Created})
+	}
+	return f, entries
+}
+
+// recordSwitch records a switch to an entry in the test server's MRU log
+func recordSwitch(t *testing.T, entry storage.SessionData, at time.Time) {
+	t.Helper()
+	to := storage.EntryVisit(entry)
+	to.Time = at
+	if err := storage.RecordSwitch(testServer, storage.Visit{}, to); err != nil {
+		t.Fatalf("RecordSwitch(%s): %v", entry.Name, err)
+	}
+}
+
+// switcher returns a switchTo for Step that switches the fake client
+func switcher(f *tmux.FakeClient, entries []storage.SessionData) func(int) bool {
+	return func(index int) bool {
+		return f.SwitchTo(tmux.Target(entries[index].ID, entries[index].Name)) == nil
+	}
+}
+
+func TestConfigAcceptsStrategies(t *testing.T) {
+	schema, err := storage.ConfigSchema()
+	if err != nil {
+		t.Fatalf("ConfigSchema: %v", err)
+	}
+	var parsed struct {
+		Properties struct {
+			Strategy struct {
+				Enum []string `json:"enum"`
+			} `json:"strategy"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		t.Fatalf("schema isn't JSON: %v", err)
+	}
+	if got := parsed.Properties.Strategy.Enum; !reflect.DeepEqual(got, StrategyNames()) {
+		t.Errorf("schema strategy enum = %v, want %v", got, StrategyNames())
+	}
+
+	tests := []struct {
+		strategy string
+		valid    bool
+	}{
+		{strategy: "stored", valid: true},
+		{strategy: "last-activity", valid: true},
+		{strategy: "sideways"},
+	}
+	for _, tt := range tests {
+		problems, err := storage.ValidateConfig("config.json", []byte(`{"strategy": "`+tt.strategy+`"}`))
+		if err != nil {
+			t.Fatalf("ValidateConfig(%s): %v", tt.strategy, err)
+		}
+		if valid := len(problems) == 0; valid != tt.valid {
+			t.Errorf("ValidateConfig(%s) = %v, want valid %v", tt.strategy, problems, tt.valid)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	const (
+		delta = iota
+		alpha
+		charlie
+		bravo
+	)
+	f, entries := newTestList(t, "delta", "alpha", "charlie", "bravo")
+
+	// alpha was switched to most often, delta most recently and charlie never
+	for range 5 {
+		recordSwitch(t, entries[alpha], testNow.Add(-3*time.Hour))
+	}
+	recordSwitch(t, entries[bravo], testNow.Add(-2*time.Hour))
+	recordSwitch(t, entries[delta], testNow.Add(-time.Minute))
+
+	activity := map[string]time.Duration{"charlie": time.Minute, "alpha": 5 * time.Minute, "bravo": 10 * time.Minute, "delta": 20 * time.Minute}
+	for name, idle := range activity {
+		if err := f.UpdateSession("="+name, func(s *tmux.Session) { s.Activity = testNow.Add(-idle) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		strategy Strategy
+		order    []int
+	}{
+		{strategy: Stored{}, order: []int{delta, alpha, charlie, bravo}},
+		{strategy: Alphabetical{}, order: []int{alpha, bravo, charlie, delta}},
+		{strategy: MostRecentlyUsed{}, order: []int{delta, bravo, alpha, charlie}},
+		{strategy: Frecency{}, order: []int{alpha, delta, bravo, charlie}},
+		{strategy: LastActivity{}, order: []int{charlie, alpha, bravo, delta}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.Name(), func(t *testing.T) {
+			navigator := New(f, testServer, tt.strategy)
+			navigator.Clock = func() time.Time { return testNow }
+
+			order, err := navigator.Order(entries)
+			if err != nil {
+				t.Fatalf("Order: %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Fatalf("Order = %v, want %v", order, tt.order)
+			}
+
+			o := tt.order
+			steps := []struct {
+				name      string
+				current   int
+				direction Direction
+				wrap      bool
+				want      []int
+			}{
+				{name: "forward", current: o[1], direction: Forward, want: []int{o[2], o[3]}},
+				{name: "backward", current: o[1], direction: Backward, want: []int{o[0]}},
+				{name: "forward wrapping", current: o[1], direction: Forward, wrap: true, want: []int{o[2], o[3], o[0], o[1]}},
+				{name: "backward wrapping", current: o[1], direction: Backward, wrap: true, want: []int{o[0], o[3], o[2], o[1]}},
+				{name: "forward at the end", current: o[3], direction: Forward},
+				{name: "backward at the start", current: o[0], direction: Backward},
+				{name: "forward from outside the list", current: -1, direction: Forward, want: o},
+				{name: "backward from outside the list", current: -1, direction: Backward, want: []int{o[3], o[2], o[1], o[0]}},
+			}
+			for _, step := range steps {
+				navigator.WrapAround = step.wrap
+				candidates, err := navigator.Candidates(entries, step.current, step.direction)
+				if err != nil {
+					t.Fatalf("%s: Candidates: %v", step.name, err)
+				}
+				if !reflect.DeepEqual(candidates, step.want) {
+					t.Errorf("%s: Candidates = %v, want %v", step.name, candidates, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCandidatesPassOverInactiveAndSkipped(t *testing.T) {
+	f, entries := newTestList(t, "work", "hidden", "missing", "skipped", "play", "archived")
+	entries[1].SetState(storage.StatusHidden, testNow)
+	entries[2].SetState(storage.StatusMissing, testNow)
+	entries[5].SetState(storage.StatusArchived, testNow)
+
+	navigator := New(f, testServer, Stored{})
+	navigator.Skip = func(entry storage.SessionData) bool { return entry.Name == "skipped" }
+
+	tests := []struct {
+		name      string
+		direction Direction
+		wrap      bool
+		want      []int
+	}{
+		{name: "forward", direction: Forward, want: []int{4}},
+		{name: "backward", direction: Backward},
+		{name: "forward wrapping", direction: Forward, wrap: true, want: []int{4, 0}},
+		{name: "backward wrapping", direction: Backward, wrap: true, want: []int{4, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			navigator.WrapAround = tt.wrap
+			candidates, err := navigator.Candidates(entries, 0, tt.direction)
+			if err != nil {
+				t.Fatalf("Candidates: %v", err)
+			}
+			if !reflect.DeepEqual(candidates, tt.want) {
+				t.Errorf("Candidates = %v, want %v", candidates, tt.want)
+			}
+		})
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   int
+		direction Direction
+		wrap      bool
+		hide      []int
+		skip      string
+		kill      []string
+		want      int
+		err       error
+	}{
+		{name: "forward", current: 0, direction: Forward, want: 1},
+		{name: "backward", current: 1, direction: Backward, want: 0},
+		{name: "wraps forward", current: 2, direction: Forward, wrap: true, want: 0},
+		{name: "wraps backward", current: 0, direction: Backward, wrap: true, want: 2},
+		{name: "passes over hidden", current: 0, direction: Forward, hide: []int{1}, want: 2},
+		{name: "passes over skipped", current: 0, direction: Forward, skip: "play", want: 2},
+		{name: "passes over killed", current: 0, direction: Forward, kill: []string{"play"}, want: 2},
+		{name: "end forward", current: 2, direction: Forward, err: ErrEnd},
+		{name: "end backward", current: 0, direction: Backward, err: ErrEnd},
+		{name: "end past inactive", current: 1, direction: Forward, hide: []int{2}, err: ErrEnd},
+		{name: "all skipped", current: -1, direction: Forward, wrap: true, hide: []int{0, 2}, skip: "play", err: ErrSkipped},
+		{name: "none valid", current: 0, direction: Forward, wrap: true, kill: []string{"work", "play", "scratch"}, err: ErrNoValid},
+		{name: "none active", current: -1, direction: Backward, wrap: true, hide: []int{0, 1, 2}, err: ErrNoActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, entries := newTestList(t, "work", "play", "scratch")
+			for _, index := range tt.hide {
+				entries[index].SetState(storage.StatusHidden, testNow)
+			}
+			for _, name := range tt.kill {
+				if err := f.KillSession("=" + name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			navigator := New(f, testServer, Stored{})
+			navigator.WrapAround = tt.wrap
+			if tt.skip != "" {
+				navigator.Skip = func(entry storage.SessionData) bool { return entry.Name == tt.skip }
+			}
+
+			index, err := navigator.Step(entries, tt.current, tt.direction, switcher(f, entries))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Step = %d, %v, want %v", index, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Step: %v", err)
+			}
+			if index != tt.want {
+				t.Errorf("Step = %d, want %d", index, tt.want)
+			}
+			if current, _ := f.CurrentSession(); current.Name != entries[tt.want].Name {
+				t.Errorf("client is on %q, want %q", current.Name, entries[tt.want].Name)
+			}
+			if navigator.Cycle != nil {
+				t.Errorf("stored strategy started a cycle: %+v", navigator.Cycle)
+			}
+		})
+	}
+}
+
+func TestStepContinuesCycle(t *testing.T) {
+	f, entries := newTestList(t, "work", "play", "scratch", "notes")
+	for i, index := range []int{2, 1, 3, 0} {
+		recordSwitch(t, entries[index], testNow.Add(time.Duration(i-10)*time.Minute))
+	}
+
+	// Switching records each visit, as rolo next does, which would send a
+	// fresh mru order straight back to the session the step came from
+	navigator := New(f, testServer, MostRecentlyUsed{})
+	switches := 0
+	switchTo := func(index int) bool {
+		switches++
+		recordSwitch(t, entries[index], testNow.Add(time.Duration(switches)*time.Minute))
+		return switcher(f, entries)(index)
+	}
+
+	current := 0
+	for _, want := range []int{3, 1, 2} {
+		index, err := navigator.Step(entries, current, Forward, switchTo)
+		if err != nil {
+			t.Fatalf("Step from %s: %v", entries[current].Name, err)
+		}
+		if index != want {
+			t.Fatalf("Step from %s = %s, want %s", entries[current].Name, entries[index].Name, entries[want].Name)
+		}
+		current = index
+	}
+	if _, err := navigator.Step(entries, current, Forward, switchTo); !errors.Is(err, ErrEnd) {
+		t.Fatalf("Step past the end of the cycle: %v, want %v", err, ErrEnd)
+	}
+
+	cycle := navigator.Cycle
+	if cycle == nil || cycle.Strategy != "mru" || !cycle.At.Of(entries[2]) {
+		t.Fatalf("Cycle = %+v, want an mru cycle at scratch", cycle)
+	}
+	index, err := navigator.Step(entries, current, Backward, switchTo)
+	if err != nil || index != 1 {
+		t.Fatalf("Step back = %d, %v, want play", index, err)
+	}
+
+	// Starting anywhere else takes the order afresh: play, scratch, notes,
+	// work, most recent first
+	candidates, err := navigator.Candidates(entries, 2, Forward)
+	if err != nil {
+		t.Fatalf("Candidates: %v", err)
+	}
+	if want := []int{3, 0}; !reflect.DeepEqual(candidates, want) {
+		t.Errorf("Candidates from outside the cycle = %v, want %v", candidates, want)
+	}
+
+	// So does another strategy, which leaves no cycle behind
+	navigator.Strategy = Stored{}
+	if _, err := navigator.Step(entries, 1, Forward, switchTo); err != nil {
+		t.Fatalf("Step with stored: %v", err)
+	}
+	if navigator.Cycle != nil {
+		t.Errorf("Cycle = %+v after a stored step, want nil", navigator.Cycle)
+	}
+}
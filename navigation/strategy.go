@@ -0,0 +1,171 @@
+package navigation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"rolo/storage"
+	"rolo/tmux"
+)
+
+// Strategy orders a session list for a Navigator
+type Strategy interface {
+	// Name is how the strategy is chosen with --strategy and in config
+	Name() string
+	// Order returns the positions of the entries in the order next steps
+	// through them, each position once
+	Order(entries []storage.SessionData, ctx *Context) ([]int, error)
+}
+
+// Strategies lists the available strategies, the first is the default
+var Strategies = []Strategy{Stored{}, Alphabetical{}, MostRecentlyUsed{}, Frecency{}, LastActivity{}}
+
+// The config accepts the strategies defined here and no others
+func init() {
+	storage.StrategyNames = StrategyNames()
+}
+
+// StrategyNames lists the names of the available strategies
+func StrategyNames() []string {
+	names := make([]string, len(Strategies))
+	for i, strategy := range Strategies {
+		names[i] = strategy.Name()
+	}
+	return names
+}
+
+// ParseStrategy finds a strategy by name, "" is the default
+func ParseStrategy(name string) (Strategy, error) {
+	if name == "" {
+		return Strategies[0], nil
+	}
+	for _, strategy := range Strategies {
+		if strategy.Name() == name {
+			return strategy, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown strategy '%s', expected one of %s", name, strings.Join(StrategyNames(), ", "))
+}
+
+// Stored keeps the order of the list
+type Stored struct{}
+
+func (Stored) Name() string { return "stored" }
+
+func (Stored) Order(entries []storage.SessionData, ctx *Context) ([]int, error) {
+	return identity(len(entries)), nil
+}
+
+// Alphabetical orders entries by the name shown for them, ignoring case
+type Alphabetical struct{}
+
+func (Alphabetical) Name() string { return "alphabetical" }
+
+func (Alphabetical) Order(entries []storage.SessionData, ctx *Context) ([]int, error) {
+	return sortedBy(entries, func(a, b storage.SessionData) bool {
+		return strings.ToLower(a.DisplayName()) < strings.ToLower(b.DisplayName())
+	}), nil
+}
+
+// MostRecentlyUsed orders entries by when rolo last switched to them, most
+// recent first, so next goes to the session visited before this one. Entries
+// rolo never switched to follow in list order.
+type MostRecentlyUsed struct{}
+
+func (MostRecentlyUsed) Name() string { return "mru" }
+
+func (MostRecentlyUsed) Order(entries []storage.SessionData, ctx *Context) ([]int, error) {
+	mru, err := ctx.MRU()
+	if err != nil {
+		return nil, err
+	}
+	return sortedBy(entries, func(a, b storage.SessionData) bool {
+		return lastVisit(mru, a).After(lastVisit(mru, b))
+	}), nil
+}
+
+// Frecency orders entries by how often and how recently rolo switched to
+// them, highest score first. Entries rolo never switched to follow in list
+// order.
+type Frecency struct{}
+
+func (Frecency) Name() string { return "frecency" }
+
+func (Frecency) Order(entries []storage.SessionData, ctx *Context) ([]int, error) {
+	mru, err := ctx.MRU()
+	if err != nil {
+		return nil, err
+	}
+	now := ctx.Now()
+	return sortedBy(entries, func(a, b storage.SessionData) bool {
+		return frecencyScore(mru, a, now) > frecencyScore(mru, b, now)
+	}), nil
+}
+
+// frecencyScore weighs the number of switches to a session by how recent
+// the last one was
+func frecencyScore(mru *storage.MRU, entry storage.SessionData, now time.Time) float64 {
+	visit, ok := mru.Find(entry)
+	if !ok {
+		return 0
+	}
+
+	weight := 0.25
+	switch age := now.Sub(visit.Time); {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(max(visit.Count, 1)) * weight
+}
+
+// LastActivity orders entries by the last activity tmux saw in their
+// session, most recent first. Entries whose session isn't running follow
+// in list order.
+type LastActivity struct{}
+
+func (LastActivity) Name() string { return "last-activity" }
+
+func (LastActivity) Order(entries []storage.SessionData, ctx *Context) ([]int, error) {
+	live, err := ctx.Live()
+	if err != nil {
+		return nil, err
+	}
+	activity := func(entry storage.SessionData) time.Time {
+		session, _ := tmux.FindSession(live, entry.ID, entry.Name)
+		return session.Activity
+	}
+	return sortedBy(entries, func(a, b storage.SessionData) bool {
+		return activity(a).After(activity(b))
+	}), nil
+}
+
+// lastVisit returns when rolo last switched to an entry, zero if never
+func lastVisit(mru *storage.MRU, entry storage.SessionData) time.Time {
+	visit, _ := mru.Find(entry)
+	return visit.Time
+}
+
+// identity returns the positions 0 to n-1
+func identity(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// sortedBy returns the positions of the entries sorted by less, keeping
+// list order between entries less doesn't tell apart
+func sortedBy(entries []storage.SessionData, less func(a, b storage.SessionData) bool) []int {
+	order := identity(len(entries))
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(entries[order[i]], entries[order[j]])
+	})
+	return order
+}
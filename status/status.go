@@ -0,0 +1,166 @@
+// Package status renders the ordered session list for the tmux status line
+package status
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"rolo/storage"
+	"rolo/tmux"
+)
+
+// ellipsis marks sessions or characters dropped to fit MaxWidth
+const ellipsis = "…"
+
+// item is one rendered session before styling
+type item struct {
+	text    string
+	current bool
+	// color is the session's own colour, used instead of the inactive one
+	color string
+}
+
+// Format renders the active sessions as a tmux format string, with the
+// current session highlighted. When cfg.MaxWidth is set, sessions furthest
+// from the current one are dropped first so the current one stays visible.
+func Format(sessions []storage.SessionData, current tmux.Session, cfg storage.StatusConfig) string {
+	items := make([]item, 0, len(sessions))
+	currentIndex := -1
+	slot := 0
+	for _, session := range sessions {
+		if !session.Active() {
+			continue
+		}
+		slot++
+
+		text := session.DisplayName()
+		if cfg.ShowIndex {
+			text = fmt.Sprintf("%d:%s", slot, text)
+		}
+
+		isCurrent := currentIndex == -1 && isCurrentSession(session, current)
+		if isCurrent {
+			currentIndex = len(items)
+		}
+		items = append(items, item{text: text, current: isCurrent, color: session.Color})
+	}
+
+	first, last, markers := 0, len(items), true
+	if cfg.MaxWidth > 0 {
+		first, last, markers = fit(items, currentIndex, cfg)
+	}
+
+	var b strings.Builder
+	if first > 0 && markers {
+		b.WriteString(ellipsis + escape(cfg.Separator))
+	}
+	for i := first; i < last; i++ {
+		if i > first {
+			b.WriteString(escape(cfg.Separator))
+		}
+		style := cfg.InactiveStyle
+		if items[i].current {
+			style = cfg.CurrentStyle
+		} else if items[i].color != "" {
+			style = strings.TrimPrefix(style+",fg="+items[i].color, ",")
+		}
+		b.WriteString(styled(items[i].text, style))
+	}
+	if last < len(items) && markers {
+		b.WriteString(escape(cfg.Separator) + ellipsis)
+	}
+
+	return b.String()
+}
+
+// isCurrentSession matches by tmux session id, falling back to the name
+// when the stored id may be from an earlier run of the server
+func isCurrentSession(session storage.SessionData, current tmux.Session) bool {
+	if session.ID != "" && current.ID != "" && !session.Created.IsZero() && session.Created.Equal(current.Created) {
+		return session.ID == current.ID
+	}
+	return session.Name == current.Name
+}
+
+// fit returns the range of items that fits in cfg.MaxWidth, growing outwards
+// from the current item, and whether there is room to mark dropped items.
+// The current item is truncated in place if it is too wide on its own.
+func fit(items []item, currentIndex int, cfg storage.StatusConfig) (int, int, bool) {
+	if len(items) == 0 {
+		return 0, 0, true
+	}
+	if currentIndex == -1 {
+		currentIndex = 0
+	}
+
+	sepWidth := width(cfg.Separator)
+	// Reserve room for an ellipsis marker on each side that has dropped items
+	markers := func(first, last int) int {
+		total := 0
+		if first > 0 {
+			total += width(ellipsis) + sepWidth
+		}
+		if last < len(items) {
+			total += width(ellipsis) + sepWidth
+		}
+		return total
+	}
+
+	first, last := currentIndex, currentIndex+1
+	used := width(items[currentIndex].text)
+	if used+markers(first, last) > cfg.MaxWidth {
+		// Not even room for the markers, spend all of it on the current item
+		items[currentIndex].text = truncate(items[currentIndex].text, cfg.MaxWidth)
+		return first, last, false
+	}
+
+	// Alternate right then left so the current item stays roughly centred
+	for grew := true; grew; {
+		grew = false
+		if last < len(items) {
+			next := used + sepWidth + width(items[last].text)
+			if next+markers(first, last+1) <= cfg.MaxWidth {
+				used, last, grew = next, last+1, true
+			}
+		}
+		if first > 0 {
+			next := used + sepWidth + width(items[first-1].text)
+			if next+markers(first-1, last) <= cfg.MaxWidth {
+				used, first, grew = next, first-1, true
+			}
+		}
+	}
+
+	return first, last, true
+}
+
+// truncate shortens text to at most max characters, ending in an ellipsis
+func truncate(text string, max int) string {
+	if width(text) <= max {
+		return text
+	}
+	if max <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:max-1]) + ellipsis
+}
+
+// width is the number of characters text occupies on the status line
+func width(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// styled wraps text in a tmux style, resetting to the status line default after
+func styled(text, style string) string {
+	if style == "" {
+		return escape(text)
+	}
+	return "#[" + style + "]" + escape(text) + "#[default]"
+}
+
+// escape doubles '#' so tmux doesn't interpret session names as formats
+func escape(text string) string {
+	return strings.ReplaceAll(text, "#", "##")
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// backupSuffix is appended to a data file's path to name its last good copy
+const backupSuffix = ".bak"
+
+// warn reports a recoverable problem, such as a corrupted file that was
+// restored from its backup
+var warn = func(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// corruptSuffix is appended to a data file's path to name the copy kept of
+// it when it couldn't be parsed
+const corruptSuffix = ".corrupt"
+
+// writeFileAtomic replaces path with data so that readers only ever see the
+// old or the new contents: data is written to a temp file in the same
+// directory, synced and renamed over path. A copy is kept as the last good
+// version for readWithBackup.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomicAt(path, path, data, perm)
+}
+
+// writeFileAtomicAt is writeFileAtomic keeping the last good copy named
+// after side rather than path, see readWithBackupAt
+func writeFileAtomicAt(path, side string, data []byte, perm os.FileMode) error {
+	if err := replaceFile(path, data, perm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(side), 0755); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := replaceFile(side+backupSuffix, data, perm); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temp file on any failure, after the rename this is a no-op
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// readWithBackup reads path and decodes it with parse. If the file can't be
+// parsed, the last good copy written by writeFileAtomic is tried instead and
+// restored over the corrupted file. The original parse error is returned if
+// the backup doesn't help either.
+func readWithBackup(path string, parse func([]byte) error) error {
+	return readWithBackupAt(path, path, parse)
+}
+
+// readWithBackupAt is readWithBackup for a file whose last good copy and
+// corrupted copy are named after side rather than path, as the config's
+// are, see configSideFile. A restored file is written through symlinks.
+func readWithBackupAt(path, side string, parse func([]byte) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	parseErr := parse(data)
+	if parseErr == nil || errors.Is(parseErr, ErrNewerVersion) {
+		return parseErr
+	}
+
+	backup, err := os.ReadFile(side + backupSuffix)
+	if err != nil {
+		return parseErr
+	}
+	if err := parse(backup); err != nil {
+		return parseErr
+	}
+
+	// Keep the corrupted file around for inspection before restoring
+	corrupt := side + corruptSuffix
+	if err := os.WriteFile(corrupt, data, 0644); err != nil {
+		corrupt = ""
+	}
+	target := path
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		target = resolved
+	}
+	if err := replaceFile(target, backup, 0644); err != nil {
+		warn("failed to restore %s from backup: %v", path, err)
+	}
+
+	if corrupt != "" {
+		warn("%s was corrupted (%v), restored the last good copy (corrupted file saved as %s)", path, parseErr, corrupt)
+	} else {
+		warn("%s was corrupted (%v), restored the last good copy", path, parseErr)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWithBackup(t *testing.T) {
+	const good = `{"version": 3, "sessions": [{"name": "api"}]}`
+
+	tests := []struct {
+		name     string
+		current  string
+		backup   string
+		want     string
+		err      bool
+		restored bool
+	}{
+		{name: "valid", current: good, backup: `{"version": 3, "sessions": []}`, want: "api"},
+		{name: "corrupt with a good backup", current: `{"version": 3, "sess`, backup: good, want: "api", restored: true},
+		{name: "empty with a good backup", current: "", backup: good, want: "api", restored: true},
+		{name: "corrupt without a backup", current: `{"version": 3, "sess`, err: true},
+		{name: "corrupt backup too", current: `{"version": 3, "sess`, backup: `[{"na`, err: true},
+		{name: "newer version is left alone", current: `{"version": 9, "sessions": []}`, backup: good, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempDir(t)
+			path := filepath.Join(dir, "rolo.json")
+			writeFile(t, path, tt.current)
+			if tt.backup != "" {
+				writeFile(t, path+backupSuffix, tt.backup)
+			}
+
+			var doc *Document
+			err := readWithBackup(path, func(data []byte) error {
+				var err error
+				doc, _, err = decodeDocument(jsonStore{}, data)
+				return err
+			})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("readWithBackup read %+v, want an error", doc)
+				}
+			} else if err != nil {
+				t.Fatalf("readWithBackup: %v", err)
+			} else if len(doc.Sessions) != 1 || doc.Sessions[0].Name != tt.want {
+				t.Errorf("sessions = %+v, want %s", doc.Sessions, tt.want)
+			}
+
+			data, _ := os.ReadFile(path)
+			corrupt, corruptErr := os.ReadFile(path + corruptSuffix)
+			if !tt.restored {
+				if string(data) != tt.current {
+					t.Errorf("rolo.json = %q, want it untouched", data)
+				}
+				if corruptErr == nil {
+					t.Errorf("rolo.json was kept as corrupt")
+				}
+				return
+			}
+			if string(data) != tt.backup {
+				t.Errorf("rolo.json = %q, want the backup restored", data)
+			}
+			if string(corrupt) != tt.current {
+				t.Errorf("corrupt copy = %q, want %q", corrupt, tt.current)
+			}
+		})
+	}
+}
+
+func TestReadWithBackupThroughSymlink(t *testing.T) {
+	dir := useTempDir(t)
+	target := filepath.Join(dir, "dots", "rolo.json")
+	path := filepath.Join(dir, "rolo.json")
+	writeFile(t, target, `{"broken`)
+	writeFile(t, path+backupSuffix, `{"list": "work"}`)
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+
+	var state activeListState
+	if err := readWithBackup(path, func(data []byte) error {
+		return json.Unmarshal(data, &state)
+	}); err != nil {
+		t.Fatalf("readWithBackup: %v", err)
+	}
+	if state.List != "work" {
+		t.Errorf("read %+v, want the backup", state)
+	}
+
+	if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink was replaced: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != `{"list": "work"}` {
+		t.Errorf("symlink target = %q, want the backup restored", data)
+	}
+}
+
+func TestWriteFileAtomicKeepsBackup(t *testing.T) {
+	dir := useTempDir(t)
+	path := filepath.Join(dir, "rolo.json")
+	for _, contents := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("writeFileAtomic: %v", err)
+		}
+		for _, name := range []string{path, path + backupSuffix} {
+			if data, err := os.ReadFile(name); err != nil || string(data) != contents {
+				t.Errorf("%s = %q, %v, want %q", filepath.Base(name), data, err, contents)
+			}
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d files, want no temp files left: %v", len(entries), entries)
+	}
+}
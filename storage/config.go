@@ -0,0 +1,538 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSchema is the JSON Schema describing config.json, less the names of
+// the strategies, see ConfigSchema
+//
+//go:embed config.schema.json
+var configSchema []byte
+
+// ConfigSchema returns the JSON Schema describing config.json, listing
+// StrategyNames as the values strategy accepts
+func ConfigSchema() ([]byte, error) {
+	if len(StrategyNames) == 0 {
+		return configSchema, nil
+	}
+	return patchJSON(configSchema, []string{"properties", "strategy", "enum"}, StrategyNames)
+}
+
+// schemaKey lets editors find the schema, it is allowed in any config
+const schemaKey = "$schema"
+
+// ConfigProblem is a key in a config file that doesn't match Config
+type ConfigProblem struct {
+	Path string
+	// Line is the 1-based line of the key, 0 when unknown
+	Line int
+	// Key is the dotted path of the key, e.g. "status.max_width"
+	Key     string
+	Message string
+	// Unknown is set for keys Config doesn't have, which are otherwise
+	// ignored, rather than values of the wrong type
+	Unknown bool
+}
+
+func (p ConfigProblem) Error() string {
+	location := p.Path
+	if p.Line > 0 {
+		location = fmt.Sprintf("%s:%d", p.Path, p.Line)
+	}
+	if p.Key == "" {
+		return fmt.Sprintf("%s: %s", location, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, p.Key, p.Message)
+}
+
+// ValidateConfig checks the contents of the config file at path, in the
+// format of its extension, against Config. Keys Config doesn't have, values
+// of the wrong type and values outside the schema's constraints are returned
+// as problems, sorted by line; an error is returned if the file can't be
+// parsed at all.
+func ValidateConfig(path string, data []byte) ([]ConfigProblem, error) {
+	store, err := StoreFor(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := store.Unmarshal(data, &doc); err != nil {
+		return nil, syntaxError(path, data, err)
+	}
+	// Normalise to the types encoding/json produces, TOML and YAML have
+	// their own map and number types
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc = nil
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	lines := keyLines(store, data)
+	var problems []ConfigProblem
+	report := func(key, message string, unknown bool) {
+		problems = append(problems, ConfigProblem{Path: path, Line: lines[key], Key: key, Message: message, Unknown: unknown})
+	}
+
+	switch root := doc.(type) {
+	case nil:
+		// An empty file keeps every default
+	case map[string]any:
+		delete(root, schemaKey)
+		checkStruct(reflect.TypeOf(Config{}), "", root, report)
+	default:
+		report("", "expected an object of settings, got "+describeValue(root), false)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+// syntaxError adds the line to JSON syntax errors, TOML and YAML errors
+// already include it
+func syntaxError(path string, data []byte, err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return fmt.Errorf("%s:%d: %v", path, lineAt(data, syntax.Offset), err)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// checkStruct reports the keys of doc that don't match the fields of t
+func checkStruct(t reflect.Type, prefix string, doc map[string]any, report func(key, message string, unknown bool)) {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := joinKey(prefix, key)
+		field, ok := fieldByKey(t, key)
+		if !ok {
+			report(path, "unknown key", true)
+			continue
+		}
+		checkValue(field.Type, path, doc[key], report)
+	}
+}
+
+// checkValue reports a value that can't be decoded into a field of type t
+func checkValue(t reflect.Type, path string, value any, report func(key, message string, unknown bool)) {
+	if isStruct(t) {
+		if object, ok := value.(map[string]any); ok {
+			checkStruct(t, path, object, report)
+		} else {
+			report(path, "expected an object, got "+describeValue(value), false)
+		}
+		return
+	}
+	if t.Kind() == reflect.Slice && isStruct(t.Elem()) {
+		if items, ok := value.([]any); ok {
+			for i, item := range items {
+				checkValue(t.Elem(), fmt.Sprintf("%s[%d]", path, i), item, report)
+			}
+		} else {
+			report(path, "expected a list, got "+describeValue(value), false)
+		}
+		return
+	}
+
+	data, _ := json.Marshal(value)
+	decoded := reflect.New(t)
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			report(path, fmt.Sprintf("expected %s, got %s", describeType(t), describeValue(value)), false)
+		} else {
+			report(path, err.Error(), false)
+		}
+		return
+	}
+	if expected := checkConstraint(path, decoded.Elem()); expected != "" {
+		report(path, fmt.Sprintf("expected %s, got %s", expected, describeValue(value)), false)
+	}
+}
+
+// StrategyNames are the values the strategy setting accepts. The
+// navigation package, which defines the strategies, sets them; any value is
+// accepted until it does.
+var StrategyNames []string
+
+// configConstraints check the values of settings beyond their type, as the
+// enum and minimum of config.schema.json do. Each returns what the setting
+// expects when the value doesn't meet it, "" otherwise.
+var configConstraints = map[string]func(value reflect.Value) string{
+	"status.max_width": atLeast(0),
+	"strategy":         strategyName,
+}
+
+// checkConstraint checks the value of the setting under a dotted key, see
+// configConstraints
+func checkConstraint(key string, value reflect.Value) string {
+	if check, ok := configConstraints[key]; ok {
+		return check(value)
+	}
+	return ""
+}
+
+// atLeast accepts whole numbers no smaller than min
+func atLeast(min int64) func(value reflect.Value) string {
+	return func(value reflect.Value) string {
+		if value.Int() < min {
+			return fmt.Sprintf("a whole number of at least %d", min)
+		}
+		return ""
+	}
+}
+
+// strategyName accepts the names in StrategyNames
+func strategyName(value reflect.Value) string {
+	if len(StrategyNames) == 0 {
+		return ""
+	}
+	return oneOf(StrategyNames)(value)
+}
+
+// oneOf accepts the given strings
+func oneOf(values []string) func(value reflect.Value) string {
+	return func(value reflect.Value) string {
+		for _, allowed := range values {
+			if value.String() == allowed {
+				return ""
+			}
+		}
+		quoted := make([]string, len(values))
+		for i, allowed := range values {
+			quoted[i] = strconv.Quote(allowed)
+		}
+		return "one of " + strings.Join(quoted, ", ")
+	}
+}
+
+// isStruct reports whether t is decoded key by key rather than from a
+// single value, as Duration is
+func isStruct(t reflect.Type) bool {
+	textUnmarshaler := reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+// fieldByKey finds the field of t stored under key, going by json tags
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == key && field.IsExported() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func describeType(t reflect.Type) string {
+	if t == reflect.TypeOf(Duration(0)) {
+		return "a duration such as \"36h\" or \"30d\""
+	}
+	if t == reflect.TypeOf(Pattern{}) {
+		return "a regular expression"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "a whole number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list"
+	}
+	return t.String()
+}
+
+func describeValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// lineAt returns the 1-based line of a byte offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// keyLines maps the dotted path of every key in data to its line
+func keyLines(store Store, data []byte) map[string]int {
+	switch store.Name() {
+	case "json":
+		return jsonKeyLines(data)
+	case "yaml":
+		return yamlKeyLines(data)
+	case "toml":
+		return tomlKeyLines(data)
+	}
+	return map[string]int{}
+}
+
+func jsonKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for dec.More() {
+				token, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := joinKey(path, token.(string))
+				lines[key] = lineAt(data, dec.InputOffset())
+				if err := walk(key); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				item := fmt.Sprintf("%s[%d]", path, i)
+				lines[item] = lineAt(data, dec.InputOffset())
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+
+	// Lines found before a syntax error are still useful
+	_ = walk("")
+	return lines
+}
+
+func yamlKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return lines
+	}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := joinKey(path, node.Content[i].Value)
+				lines[key] = node.Content[i].Line
+				walk(node.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				item := fmt.Sprintf("%s[%d]", path, i)
+				lines[item] = child.Line
+				walk(child, item)
+			}
+		}
+	}
+	walk(&doc, "")
+	return lines
+}
+
+var (
+	tomlTable      = regexp.MustCompile(`^\[\s*([^\[\]]+?)\s*\]`)
+	tomlArrayTable = regexp.MustCompile(`^\[\[\s*([^\[\]]+?)\s*\]\]`)
+	tomlKey        = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_.\-\s"']+?)\s*=`)
+)
+
+// tomlKeyLines finds the lines of tables and keys with a line based scan,
+// which covers the way config files are written; keys inside inline tables
+// or after multi-line strings may be missed and are reported without line
+func tomlKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	arrays := map[string]int{}
+	table := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if m := tomlArrayTable.FindStringSubmatch(text); m != nil {
+			name := tomlPath(m[1])
+			table = fmt.Sprintf("%s[%d]", name, arrays[name])
+			arrays[name]++
+			if _, ok := lines[name]; !ok {
+				lines[name] = line
+			}
+			lines[table] = line
+			continue
+		}
+		if m := tomlTable.FindStringSubmatch(text); m != nil {
+			table = tomlPath(m[1])
+			lines[table] = line
+			continue
+		}
+		if m := tomlKey.FindStringSubmatch(text); m != nil {
+			lines[joinKey(table, tomlPath(m[1]))] = line
+		}
+	}
+	return lines
+}
+
+// tomlPath turns a TOML dotted key into a plain dotted path
+func tomlPath(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ConfigKeys lists the dotted keys of every setting, in declaration order
+func ConfigKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" || name == schemaKey {
+				continue
+			}
+			if isStruct(t.Field(i).Type) {
+				walk(t.Field(i).Type, joinKey(prefix, name))
+			} else {
+				keys = append(keys, joinKey(prefix, name))
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// configField finds the setting stored under a dotted key
+func configField(config *Config, key string) (reflect.Value, error) {
+	value := reflect.ValueOf(config).Elem()
+	for _, part := range strings.Split(key, ".") {
+		var field reflect.StructField
+		ok := isStruct(value.Type())
+		if ok {
+			field, ok = fieldByKey(value.Type(), part)
+		}
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown setting '%s', expected one of %s", key, strings.Join(ConfigKeys(), ", "))
+		}
+		value = value.FieldByIndex(field.Index)
+	}
+	return value, nil
+}
+
+// GetConfigValue renders the setting under a dotted key, e.g.
+// "status.separator". Strings are returned as is, settings made of several
+// values as JSON.
+func GetConfigValue(config *Config, key string) (string, error) {
+	value, err := configField(config, key)
+	if err != nil {
+		return "", err
+	}
+
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool, reflect.Int, reflect.Int64:
+		return fmt.Sprint(value.Interface()), nil
+	}
+	data, err := json.MarshalIndent(value.Interface(), "", "  ")
+	return string(data), err
+}
+
+// SetConfigValue parses text as the setting under a dotted key. Settings
+// made of several values, such as lists, are given as JSON. Values the
+// schema doesn't allow, e.g. an unknown strategy, are errors.
+func SetConfigValue(config *Config, key, text string) error {
+	value, err := configField(config, key)
+	if err != nil {
+		return err
+	}
+
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s expects true or false, got '%s'", key, text)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s expects a whole number, got '%s'", key, text)
+		}
+		value.SetInt(n)
+	default:
+		parsed := reflect.New(value.Type())
+		if err := json.Unmarshal([]byte(text), parsed.Interface()); err != nil {
+			return fmt.Errorf("%s expects %s as JSON: %v", key, describeType(value.Type()), err)
+		}
+		value.Set(parsed.Elem())
+	}
+	if expected := checkConstraint(key, value); expected != "" {
+		return fmt.Errorf("%s expects %s, got '%s'", key, expected, text)
+	}
+	return nil
+}
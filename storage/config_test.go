@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigKeepsInvalidEdits(t *testing.T) {
+	const saved = "{\n  \"wrap_around\": true\n}\n"
+
+	tests := []struct {
+		name     string
+		edit     string
+		err      string
+		restored bool
+		wrap     bool
+	}{
+		{name: "valid", edit: "{\"wrap_around\": false, \"strategy\": \"mru\"}"},
+		{name: "unknown key", edit: "{\"wrap_arround\": true}"},
+		{name: "wrong type", edit: "{\n  \"status\": {\n    \"max_width\": \"60\"\n  }\n}\n", err: "config.json:3: status.max_width"},
+		{name: "below the minimum", edit: "{\"status\": {\"max_width\": -1}}", err: "status.max_width"},
+		{name: "syntax error", edit: "{\"wrap_around\": tru", restored: true, wrap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempDir(t)
+			path := filepath.Join(dir, "config.json")
+			// Saving twice leaves the first write as the backup
+			for range 2 {
+				if err := writeFileAtomic(path, []byte(saved), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeFile(t, path, tt.edit)
+
+			config, err := LoadConfig()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadConfig error = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			} else if config.WrapAround != tt.wrap {
+				t.Errorf("WrapAround = %v, want %v", config.WrapAround, tt.wrap)
+			}
+
+			data, _ := os.ReadFile(path)
+			_, corruptErr := os.Stat(path + corruptSuffix)
+			if tt.restored {
+				if string(data) != saved {
+					t.Errorf("config.json = %q, want the backup restored", data)
+				}
+				if corruptErr != nil {
+					t.Errorf("the unparsable edit wasn't kept: %v", corruptErr)
+				}
+				return
+			}
+			if string(data) != tt.edit {
+				t.Errorf("config.json = %q, want the edit %q left alone", data, tt.edit)
+			}
+			if corruptErr == nil {
+				t.Errorf("the edit was moved aside as corrupt")
+			}
+		})
+	}
+}
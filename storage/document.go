@@ -0,0 +1,452 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Schema versions of a session list
+const (
+	// versionText is the legacy rolo.txt format, one session name per line
+	versionText = 0
+	// versionArray is a bare JSON array of SessionData
+	versionArray = 1
+	// versionEnvelope wraps the sessions in a Document
+	versionEnvelope = 2
+	// versionStatus replaces the deleted flag with a lifecycle status
+	versionStatus = 3
+
+	// CurrentVersion is the schema version written by this build
+	CurrentVersion = versionStatus
+)
+
+// Document is the on-disk form of a session list
+type Document struct {
+	Version  int           `json:"version" toml:"version" yaml:"version"`
+	Sessions []SessionData `json:"sessions" toml:"sessions" yaml:"sessions"`
+	Metadata Metadata      `json:"metadata" toml:"metadata" yaml:"metadata"`
+}
+
+// Metadata describes a saved session list
+type Metadata struct {
+	// Server is the key of the tmux server the list belongs to
+	Server string `json:"server,omitempty" toml:"server,omitempty" yaml:"server,omitempty"`
+	// List is the name of the list
+	List string `json:"list,omitempty" toml:"list,omitempty" yaml:"list,omitempty"`
+	// UpdatedAt is when the list was last saved
+	UpdatedAt time.Time `json:"updated_at,omitempty" toml:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+// ErrNewerVersion is returned for lists written by a newer rolo, which are
+// left untouched rather than treated as corrupt
+var ErrNewerVersion = errors.New("written by a newer version of rolo")
+
+// migration upgrades the raw contents of a session list by one version
+type migration struct {
+	description string
+	apply       func(data []byte) ([]byte, error)
+}
+
+// migrations[v] upgrades a list from version v to v+1
+var migrations = []migration{
+	versionText: {
+		description: "convert rolo.txt lines to a JSON array",
+		apply:       migrateTextToArray,
+	},
+	versionArray: {
+		description: "wrap the session array in a versioned document",
+		apply:       migrateArrayToEnvelope,
+	},
+	versionEnvelope: {
+		description: "replace the deleted flag with a status",
+		apply:       migrateDeletedToStatus,
+	},
+}
+
+// Migrations work on generic values rather than SessionData, which only
+// describes the current version
+
+func migrateTextToArray(data []byte) ([]byte, error) {
+	sessions := []map[string]any{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			sessions = append(sessions, map[string]any{"name": line, "deleted": false})
+		}
+	}
+	return json.Marshal(sessions)
+}
+
+func migrateArrayToEnvelope(data []byte) ([]byte, error) {
+	var sessions []any
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []any{}
+	}
+	return json.Marshal(map[string]any{"version": versionEnvelope, "sessions": sessions})
+}
+
+// detectVersion returns the schema version of a JSON session list
+func detectVersion(data []byte) (int, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return 0, fmt.Errorf("empty file")
+	case trimmed[0] == '[':
+		return versionArray, nil
+	case trimmed[0] == '{':
+		var header struct {
+			Version *int `json:"version"`
+		}
+		if err := json.Unmarshal(trimmed, &header); err != nil {
+			return 0, err
+		}
+		if header.Version == nil {
+			return 0, fmt.Errorf("missing version field")
+		}
+		return *header.Version, nil
+	default:
+		return 0, fmt.Errorf("unrecognised session list format")
+	}
+}
+
+// upgrade runs the migration chain on data from version from to
+// CurrentVersion, returning the upgraded data and the steps applied
+func upgrade(data []byte, from int) ([]byte, []string, error) {
+	if from > CurrentVersion {
+		return nil, nil, fmt.Errorf("version %d %w, this one supports up to version %d", from, ErrNewerVersion, CurrentVersion)
+	}
+	if from < 0 {
+		return nil, nil, fmt.Errorf("invalid version %d", from)
+	}
+
+	var steps []string
+	for v := from; v < CurrentVersion; v++ {
+		upgraded, err := migrations[v].apply(data)
+		if err != nil {
+			return nil, steps, fmt.Errorf("failed to migrate from version %d: %w", v, err)
+		}
+		data = upgraded
+		steps = append(steps, fmt.Sprintf("v%d → v%d: %s", v, v+1, migrations[v].description))
+	}
+	return data, steps, nil
+}
+
+// decodeDocument parses a session list of any supported version
+// Returns the document and the version it was stored as
+func decodeDocument(store Store, data []byte) (*Document, int, error) {
+	data, err := toJSON(store, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	version, err := detectVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	upgraded, _, err := upgrade(data, version)
+	if err != nil {
+		return nil, version, err
+	}
+
+	var doc Document
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		return nil, version, err
+	}
+	if doc.Sessions == nil {
+		doc.Sessions = []SessionData{}
+	}
+	return &doc, version, nil
+}
+
+// encodeDocument renders a session list in the current schema, stamping
+// the metadata with the time of the save
+func encodeDocument(store Store, meta Metadata, sessions []SessionData) ([]byte, error) {
+	if sessions == nil {
+		sessions = []SessionData{}
+	}
+	meta.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	doc := Document{
+		Version:  CurrentVersion,
+		Sessions: sessions,
+		Metadata: meta,
+	}
+	return store.Marshal(doc)
+}
+
+// versionBackupPath names the copy of a file kept before migrating it
+func versionBackupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+// MigrationReport describes the migration of one session list file
+type MigrationReport struct {
+	// Source is the file that is read
+	Source string
+	// Target is the file the current version is written to
+	Target string
+	// Backup is where the original contents are kept
+	Backup string
+	From   int
+	To     int
+	// Sessions is the number of sessions in the list
+	Sessions int
+	// Steps are the migrations that run, empty if already current
+	Steps []string
+}
+
+// NeedsMigration reports whether the file is not at the current version or
+// not at its current location
+func (r MigrationReport) NeedsMigration() bool {
+	return r.From != r.To || r.Source != r.Target
+}
+
+// CheckMigrations reports what migrating every session list would change,
+// without writing anything
+func CheckMigrations() ([]MigrationReport, error) {
+	return migrateAll(false, nil)
+}
+
+// MigrateAll upgrades every session list to the current version and
+// location, keeping a backup of each original file. Each list is migrated
+// holding its lock, except for lists whose lock the caller passes in as
+// held already.
+func MigrateAll(held ...*FileLock) ([]MigrationReport, error) {
+	return migrateAll(true, held)
+}
+
+func migrateAll(write bool, held []*FileLock) ([]MigrationReport, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	// Gather every list: the default list, per-server lists, named lists,
+	// and those still in the legacy location
+	defaultPath, err := GetConfigJSONPath()
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{defaultPath}
+	dirs := []string{paths.StateDir}
+	if paths.LegacyDir != "" && paths.LegacyDir != paths.StateDir {
+		dirs = append(dirs, paths.LegacyDir)
+	}
+	targets = append(targets, globLists(filepath.Join(paths.StateDir, listsDir, "*", "rolo.*"))...)
+	targets = append(targets, globLists(filepath.Join(paths.StateDir, listsDir, "*", "servers", "*"))...)
+	for _, dir := range dirs {
+		for _, match := range globLists(filepath.Join(dir, "servers", "*")) {
+			rel, _ := filepath.Rel(dir, match)
+			targets = append(targets, filepath.Join(paths.StateDir, rel))
+		}
+	}
+
+	var reports []MigrationReport
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		report, ok, err := migrateList(target, write, held)
+		if err != nil {
+			return reports, err
+		}
+		if ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// globLists returns the session list files matching pattern, skipping
+// backups, lock files and other files that aren't in a supported format
+func globLists(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	lists := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if supportedExt(match) {
+			lists = append(lists, match)
+		}
+	}
+	return lists
+}
+
+// locateList finds the file holding the list that belongs at target: target
+// itself, its legacy location, or for the default list the legacy rolo.txt
+// Returns "" if there is no list, and whether the source is rolo.txt.
+func locateList(target string) (string, bool, error) {
+	if _, err := os.Stat(target); err == nil {
+		return target, false, nil
+	}
+	if legacy := legacyFile(target); legacy != "" {
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, false, nil
+		}
+	}
+
+	defaultPath, err := GetConfigJSONPath()
+	if err != nil || target != defaultPath {
+		return "", false, err
+	}
+	txtPath, err := GetConfigPath()
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := os.Stat(txtPath); err == nil {
+		return txtPath, true, nil
+	}
+	return "", false, nil
+}
+
+// migrateList is migrateFile holding the list's lock while writing, like
+// SaveSessionsData's callers do. Locks are per open file, so a lock in held
+// is used as it is rather than taken again, which would block forever.
+func migrateList(target string, write bool, held []*FileLock) (MigrationReport, bool, error) {
+	if write && !holdsAny(held, target+".lock") {
+		lock, err := lockPath(target + ".lock")
+		if err != nil {
+			return MigrationReport{}, false, err
+		}
+		defer lock.Unlock()
+	}
+	return migrateFile(target, write)
+}
+
+// holdsAny reports whether one of locks is the held lock file at path
+func holdsAny(locks []*FileLock, path string) bool {
+	for _, lock := range locks {
+		if lock.holds(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateFile upgrades the list that belongs at target, see MigrateAll
+// The bool result is false if there is no such list. When writing, callers
+// hold the list's lock.
+func migrateFile(target string, write bool) (MigrationReport, bool, error) {
+	source, text, err := locateList(target)
+	if err != nil || source == "" {
+		return MigrationReport{}, false, err
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return MigrationReport{}, false, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	version := versionText
+	current := data
+	if !text {
+		store, err := StoreFor(source)
+		if err != nil {
+			return MigrationReport{}, false, err
+		}
+		if current, err = toJSON(store, data); err == nil {
+			version, err = detectVersion(current)
+		}
+		if err != nil {
+			return MigrationReport{}, false, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+	}
+
+	upgraded, steps, err := upgrade(current, version)
+	if err != nil {
+		return MigrationReport{}, false, fmt.Errorf("%s: %w", source, err)
+	}
+	var doc Document
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		return MigrationReport{}, false, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+
+	report := MigrationReport{
+		Source:   source,
+		Target:   target,
+		From:     version,
+		To:       CurrentVersion,
+		Sessions: len(doc.Sessions),
+		Steps:    steps,
+	}
+	if !report.NeedsMigration() {
+		return report, true, nil
+	}
+	report.Backup = versionBackupPath(source, version)
+
+	if write {
+		if err := writeMigrated(report, data, upgraded); err != nil {
+			return report, true, err
+		}
+	}
+	return report, true, nil
+}
+
+// writeMigrated keeps the original contents as the report's backup and
+// writes the upgraded list to its target. Files moved from another location
+// are removed once the backup exists.
+func writeMigrated(report MigrationReport, original, upgraded []byte) error {
+	if err := os.WriteFile(report.Backup, original, 0644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", report.Source, err)
+	}
+
+	store, err := StoreFor(report.Target)
+	if err != nil {
+		return err
+	}
+	var doc Document
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		return err
+	}
+	// Lists from before the envelope don't know where they belong, the
+	// server is recovered from the file name as well as it can be
+	meta := doc.Metadata
+	if meta.Server == "" {
+		name := filepath.Base(report.Target)
+		meta.Server = strings.TrimSuffix(name, filepath.Ext(name))
+		if meta.Server == "rolo" {
+			meta.Server = DefaultServer
+		}
+	}
+	data, err := encodeDocument(store, meta, doc.Sessions)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(report.Target), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := writeFileAtomic(report.Target, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", report.Target, err)
+	}
+
+	if report.Source != report.Target {
+		if err := os.Remove(report.Source); err != nil {
+			return fmt.Errorf("failed to remove %s after migrating it: %w", report.Source, err)
+		}
+	}
+	return nil
+}
+
+// EncodeList renders a session list as a current document in the format of
+// store, as it would be saved
+func EncodeList(store Store, meta Metadata, sessions []SessionData) ([]byte, error) {
+	return encodeDocument(store, meta, sessions)
+}
+
+// DecodeList parses a session list document of any supported version,
+// including the bare array form
+func DecodeList(store Store, data []byte) ([]SessionData, error) {
+	doc, _, err := decodeDocument(store, data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Sessions, nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useTempDir points the package at a fresh temp directory for one test
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	SetConfigDir(dir)
+	t.Cleanup(func() { SetConfigDir("") })
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateAllUnderHeldLock(t *testing.T) {
+	tests := []struct {
+		name string
+		held []ListKey
+	}{
+		{name: "no lock held"},
+		{name: "default list held", held: []ListKey{{}}},
+		{name: "server list held", held: []ListKey{{Server: "work"}}},
+		{name: "both held", held: []ListKey{{}, {Server: "work"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempDir(t)
+			writeFile(t, filepath.Join(dir, "rolo.txt"), "api\nweb\n")
+			writeFile(t, filepath.Join(dir, "servers", "work.json"), `[{"name": "db", "deleted": true}]`)
+
+			var locks []*FileLock
+			for _, key := range tt.held {
+				lock, err := LockSessions(key)
+				if err != nil {
+					t.Fatalf("LockSessions(%v): %v", key, err)
+				}
+				defer lock.Unlock()
+				locks = append(locks, lock)
+			}
+
+			type result struct {
+				reports []MigrationReport
+				err     error
+			}
+			done := make(chan result, 1)
+			go func() {
+				reports, err := MigrateAll(locks...)
+				done <- result{reports, err}
+			}()
+
+			var got result
+			select {
+			case got = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("MigrateAll blocked on a lock")
+			}
+			if got.err != nil {
+				t.Fatalf("MigrateAll: %v", got.err)
+			}
+			if len(got.reports) != 2 {
+				t.Fatalf("MigrateAll reported %d lists, want 2: %+v", len(got.reports), got.reports)
+			}
+
+			lists := []struct {
+				key      ListKey
+				sessions []SessionData
+			}{
+				{key: ListKey{}, sessions: []SessionData{{Name: "api"}, {Name: "web"}}},
+				{key: ListKey{Server: "work"}, sessions: []SessionData{{Name: "db", Status: StatusHidden}}},
+			}
+			for _, list := range lists {
+				path, err := GetListPath(list.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				doc, err := readDocument(path)
+				if err != nil {
+					t.Fatalf("%v wasn't migrated: %v", list.key, err)
+				}
+				data, _ := os.ReadFile(path)
+				if version, _ := detectVersion(data); version != CurrentVersion {
+					t.Errorf("%v is at version %d, want %d", list.key, version, CurrentVersion)
+				}
+				if len(doc.Sessions) != len(list.sessions) {
+					t.Fatalf("%v sessions = %+v, want %+v", list.key, doc.Sessions, list.sessions)
+				}
+				for i, session := range list.sessions {
+					if doc.Sessions[i].Name != session.Name || doc.Sessions[i].Status != session.Status {
+						t.Errorf("%v session %d = %+v, want %+v", list.key, i, doc.Sessions[i], session)
+					}
+				}
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "rolo.txt.v0.bak")); err != nil {
+				t.Errorf("rolo.txt wasn't kept as a backup: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "rolo.txt")); !os.IsNotExist(err) {
+				t.Errorf("rolo.txt is still there after moving to rolo.json")
+			}
+
+			// Running again finds nothing left to do
+			reports, err := MigrateAll(locks...)
+			if err != nil {
+				t.Fatalf("second MigrateAll: %v", err)
+			}
+			for _, report := range reports {
+				if report.NeedsMigration() {
+					t.Errorf("%s still needs migrating: %+v", report.Target, report)
+				}
+			}
+		})
+	}
+}
+
+func TestUpgradeVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		data     string
+		steps    int
+		sessions []SessionData
+		err      error
+	}{
+		{
+			name:     "text",
+			version:  versionText,
+			data:     "api\n\n  web \n",
+			steps:    3,
+			sessions: []SessionData{{Name: "api"}, {Name: "web"}},
+		},
+		{
+			name:     "array",
+			version:  versionArray,
+			data:     `[{"name": "api", "deleted": false}, {"name": "db", "deleted": true}]`,
+			steps:    2,
+			sessions: []SessionData{{Name: "api"}, {Name: "db", Status: StatusHidden}},
+		},
+		{
+			name:     "empty array",
+			version:  versionArray,
+			data:     `[]`,
+			steps:    2,
+			sessions: []SessionData{},
+		},
+		{
+			name:     "envelope",
+			version:  versionEnvelope,
+			data:     `{"version": 2, "sessions": [{"name": "db", "deleted": true}, {"name": "web"}]}`,
+			steps:    1,
+			sessions: []SessionData{{Name: "db", Status: StatusHidden}, {Name: "web"}},
+		},
+		{
+			name:     "current",
+			version:  versionStatus,
+			data:     `{"version": 3, "sessions": [{"name": "db", "status": "missing"}]}`,
+			sessions: []SessionData{{Name: "db", Status: StatusMissing}},
+		},
+		{
+			name:    "newer",
+			version: CurrentVersion + 1,
+			data:    `{"version": 4, "sessions": []}`,
+			err:     ErrNewerVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upgraded, steps, err := upgrade([]byte(tt.data), tt.version)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("upgrade error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("upgrade: %v", err)
+			}
+			if len(steps) != tt.steps {
+				t.Errorf("upgrade took steps %q, want %d", steps, tt.steps)
+			}
+
+			if version, err := detectVersion(upgraded); err != nil || version != CurrentVersion {
+				t.Errorf("upgraded to version %d (%v), want %d", version, err, CurrentVersion)
+			}
+			var doc Document
+			if err := json.Unmarshal(upgraded, &doc); err != nil {
+				t.Fatalf("upgraded list isn't a document: %v", err)
+			}
+			if !reflect.DeepEqual(doc.Sessions, tt.sessions) {
+				t.Errorf("sessions = %+v, want %+v", doc.Sessions, tt.sessions)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+// UngroupedLabel names the entries without a group where groups are shown
+const UngroupedLabel = "ungrouped"
+
+// GroupLabel returns the name of a group for display
+func GroupLabel(group string) string {
+	if group == "" {
+		return UngroupedLabel
+	}
+	return group
+}
+
+// HasGroups reports whether any entry of a list belongs to a group
+func HasGroups(sessions []SessionData) bool {
+	for _, session := range sessions {
+		if session.Group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupNames returns the groups of a list in the order they first appear,
+// "" standing for the entries without a group
+func GroupNames(sessions []SessionData) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, session := range sessions {
+		if !seen[session.Group] {
+			seen[session.Group] = true
+			names = append(names, session.Group)
+		}
+	}
+	return names
+}
+
+// GroupOrder returns the positions of a list's entries with the entries of
+// each group brought together behind the first one, keeping their order
+func GroupOrder(sessions []SessionData) []int {
+	order := make([]int, 0, len(sessions))
+	for _, group := range GroupNames(sessions) {
+		for i, session := range sessions {
+			if session.Group == group {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+// GroupSessions returns the list with the entries of each group together,
+// see GroupOrder
+func GroupSessions(sessions []SessionData) []SessionData {
+	grouped := make([]SessionData, 0, len(sessions))
+	for _, i := range GroupOrder(sessions) {
+		grouped = append(grouped, sessions[i])
+	}
+	return grouped
+}
+
+// GroupBounds returns the span [start, end) of the group of the entry at
+// index, in a list whose groups are together
+func GroupBounds(sessions []SessionData, index int) (int, int) {
+	group := sessions[index].Group
+	start, end := index, index+1
+	for start > 0 && sessions[start-1].Group == group {
+		start--
+	}
+	for end < len(sessions) && sessions[end].Group == group {
+		end++
+	}
+	return start, end
+}
+
+// MoveGroup moves the group of the entry at index past the neighbouring
+// group, down for a positive step and up for a negative one, in a list
+// whose groups are together. Returns the new index of the entry, or index
+// if the group is already at that end of the list.
+func MoveGroup(sessions []SessionData, index, step int) int {
+	start, end := GroupBounds(sessions, index)
+	if step > 0 {
+		if end == len(sessions) {
+			return index
+		}
+		_, nextEnd := GroupBounds(sessions, end)
+		rotate(sessions[start:nextEnd], end-start)
+		return index + nextEnd - end
+	}
+	if start == 0 {
+		return index
+	}
+	prevStart, _ := GroupBounds(sessions, start-1)
+	rotate(sessions[prevStart:end], start-prevStart)
+	return index - (start - prevStart)
+}
+
+// rotate moves the first n entries of sessions to its end
+func rotate(sessions []SessionData, n int) {
+	rotated := append(append([]SessionData{}, sessions[n:]...), sessions[:n]...)
+	copy(sessions, rotated)
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Cause records why a session list was saved
+type Cause string
+
+const (
+	// CauseInitial is the list as it was before history was first kept
+	CauseInitial Cause = "initial"
+	// CauseTUISave is a save from the interactive UI
+	CauseTUISave Cause = "tui-save"
+	// CausePopulate replaced the list with the live tmux sessions
+	CausePopulate Cause = "populate"
+	// CauseAutoDelete marked a session that no longer exists as missing
+	CauseAutoDelete Cause = "auto-delete"
+	// CauseRename followed sessions renamed in tmux
+	CauseRename Cause = "rename"
+	// CauseServerRestart cleared session ids after starting a new server
+	CauseServerRestart Cause = "server-restart"
+	// CauseKill removed or tombstoned a killed session
+	CauseKill Cause = "kill"
+	// CauseListNew created a named list
+	CauseListNew Cause = "list-new"
+	// CauseMeta changed the metadata of a session
+	CauseMeta Cause = "meta"
+	// CauseImport merged or replaced the list with an imported one
+	CauseImport Cause = "import"
+	// CauseResurrect made missing sessions that are running again active
+	CauseResurrect Cause = "resurrect"
+	// CauseExpire archived or removed entries that were gone for too long
+	CauseExpire Cause = "expire"
+	// CauseRepair removed duplicate and unnamed entries
+	CauseRepair Cause = "repair"
+)
+
+// maxHistory is the number of snapshots kept per list
+const maxHistory = 50
+
+// historySuffix is appended to a list's path to name its history file
+const historySuffix = ".history"
+
+// Snapshot is a session list as it was saved at one point in time
+type Snapshot struct {
+	Time     time.Time     `json:"time"`
+	Cause    Cause         `json:"cause"`
+	Sessions []SessionData `json:"sessions"`
+}
+
+// History is the bounded undo history of a session list
+type History struct {
+	// Snapshots are ordered oldest first
+	Snapshots []Snapshot `json:"snapshots"`
+	// Position is the index of the snapshot the list was last saved as,
+	// snapshots after it can be redone
+	Position int `json:"position"`
+}
+
+// LoadHistory reads the history of a session list
+// Returns an empty history if none has been kept yet
+func LoadHistory(key ListKey) (*History, error) {
+	path, err := GetListPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return loadHistory(path)
+}
+
+func loadHistory(listPath string) (*History, error) {
+	path := listPath + historySuffix
+	history := &History{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return history, nil
+	}
+
+	err := readWithBackup(path, func(data []byte) error {
+		history = &History{}
+		if err := json.Unmarshal(data, history); err != nil {
+			return err
+		}
+		return upgradeSnapshots(data, history)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	if history.Position < 0 || history.Position >= len(history.Snapshots) {
+		history.Position = len(history.Snapshots) - 1
+	}
+	return history, nil
+}
+
+// upgradeSnapshots carries the deleted flag of snapshots saved before list
+// entries had a status over as hidden, like the v2 to v3 list migration
+func upgradeSnapshots(data []byte, history *History) error {
+	var legacy struct {
+		Snapshots []struct {
+			Sessions []struct {
+				Deleted bool `json:"deleted"`
+			} `json:"sessions"`
+		} `json:"snapshots"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	for i, snapshot := range legacy.Snapshots {
+		for j, session := range snapshot.Sessions {
+			if session.Deleted && history.Snapshots[i].Sessions[j].Status == "" {
+				history.Snapshots[i].Sessions[j].Status = StatusHidden
+			}
+		}
+	}
+	return nil
+}
+
+func saveHistory(listPath string, history *History) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	if err := writeFileAtomic(listPath+historySuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// record adds a snapshot after the current position, dropping the snapshots
+// that could have been redone and the oldest ones beyond maxHistory
+func (h *History) record(snapshot Snapshot) {
+	if len(h.Snapshots) > 0 {
+		h.Snapshots = h.Snapshots[:h.Position+1]
+		// Saving the same list again, e.g. with the same rename, adds nothing
+		if reflect.DeepEqual(h.Snapshots[h.Position].Sessions, snapshot.Sessions) {
+			return
+		}
+	}
+
+	h.Snapshots = append(h.Snapshots, snapshot)
+	if len(h.Snapshots) > maxHistory {
+		h.Snapshots = h.Snapshots[len(h.Snapshots)-maxHistory:]
+	}
+	h.Position = len(h.Snapshots) - 1
+}
+
+// Current returns the snapshot the list was last saved as
+func (h *History) Current() (Snapshot, bool) {
+	if len(h.Snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return h.Snapshots[h.Position], true
+}
+
+// recordSave adds a save of the list at listPath to its history. It is
+// called before the list is written so the first save can also keep the
+// list as it was before history existed.
+func recordSave(listPath string, cause Cause, sessions []SessionData) error {
+	history, err := loadHistory(listPath)
+	if err != nil {
+		return err
+	}
+
+	if len(history.Snapshots) == 0 {
+		if previous, err := readDocument(listPath); err == nil {
+			when := previous.Metadata.UpdatedAt
+			if when.IsZero() {
+				when = time.Now()
+			}
+			history.record(Snapshot{Time: when.UTC(), Cause: CauseInitial, Sessions: previous.Sessions})
+		}
+	}
+
+	history.record(Snapshot{
+		Time:     time.Now().UTC().Truncate(time.Second),
+		Cause:    cause,
+		Sessions: append([]SessionData{}, sessions...),
+	})
+	return saveHistory(listPath, history)
+}
+
+// readDocument reads the session list at path without migrating or
+// restoring it
+func readDocument(path string) (*Document, error) {
+	store, err := StoreFor(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, _, err := decodeDocument(store, data)
+	return doc, err
+}
+
+// Undo restores the list to the snapshot steps before the current one
+// Returns the updated history, whose current snapshot is the one restored.
+func Undo(key ListKey, steps int) (*History, error) {
+	return moveHistory(key, -steps)
+}
+
+// Redo restores the list to the snapshot steps after the current one,
+// reversing Undo
+func Redo(key ListKey, steps int) (*History, error) {
+	return moveHistory(key, steps)
+}
+
+func moveHistory(key ListKey, offset int) (*History, error) {
+	path, err := GetListPath(key)
+	if err != nil {
+		return nil, err
+	}
+	history, err := loadHistory(path)
+	if err != nil {
+		return nil, err
+	}
+
+	target := history.Position + offset
+	switch {
+	case offset < 0 && (len(history.Snapshots) == 0 || history.Position == 0):
+		return nil, fmt.Errorf("nothing to undo")
+	case offset > 0 && history.Position >= len(history.Snapshots)-1:
+		return nil, fmt.Errorf("nothing to redo")
+	case target < 0:
+		target = 0
+	case target >= len(history.Snapshots):
+		target = len(history.Snapshots) - 1
+	}
+
+	// Undo and redo move through the history rather than adding to it
+	history.Position = target
+	if err := writeList(key, path, history.Snapshots[target].Sessions); err != nil {
+		return nil, err
+	}
+	if err := saveHistory(path, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
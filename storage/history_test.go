@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCreateListRecordsHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		key  ListKey
+	}{
+		{name: "default server", key: ListKey{List: "foo"}},
+		{name: "other server", key: ListKey{Server: "work", List: "foo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempDir(t)
+			if err := CreateList(tt.key, []SessionData{{Name: "api"}}); err != nil {
+				t.Fatalf("CreateList: %v", err)
+			}
+
+			history, err := LoadHistory(tt.key)
+			if err != nil {
+				t.Fatalf("LoadHistory: %v", err)
+			}
+			current, ok := history.Current()
+			if !ok || len(history.Snapshots) != 1 {
+				t.Fatalf("history = %+v, want the one save", history)
+			}
+			if current.Cause != CauseListNew || len(current.Sessions) != 1 || current.Sessions[0].Name != "api" {
+				t.Errorf("snapshot = %+v, want the new list", current)
+			}
+		})
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	useTempDir(t)
+	key := ListKey{}
+	for _, names := range [][]string{{"api"}, {"api", "web"}, {"api", "web", "db"}} {
+		saveNames(t, key, names...)
+	}
+
+	steps := []struct {
+		name string
+		move func() (*History, error)
+		want []string
+		err  string
+	}{
+		{name: "undo", move: func() (*History, error) { return Undo(key, 1) }, want: []string{"api", "web"}},
+		{name: "undo past the start", move: func() (*History, error) { return Undo(key, 5) }, want: []string{"api"}},
+		{name: "nothing to undo", move: func() (*History, error) { return Undo(key, 1) }, want: []string{"api"}, err: "nothing to undo"},
+		{name: "redo", move: func() (*History, error) { return Redo(key, 2) }, want: []string{"api", "web", "db"}},
+		{name: "nothing to redo", move: func() (*History, error) { return Redo(key, 1) }, want: []string{"api", "web", "db"}, err: "nothing to redo"},
+		{name: "undo again", move: func() (*History, error) { return Undo(key, 2) }, want: []string{"api"}},
+		{name: "saving drops the redo", move: func() (*History, error) {
+			saveNames(t, key, "notes")
+			return Redo(key, 1)
+		}, want: []string{"notes"}, err: "nothing to redo"},
+		{name: "undo the new save", move: func() (*History, error) { return Undo(key, 1) }, want: []string{"api"}},
+	}
+
+	for _, step := range steps {
+		history, err := step.move()
+		if step.err != "" {
+			if err == nil || err.Error() != step.err {
+				t.Fatalf("%s: error = %v, want %q", step.name, err, step.err)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		} else if current, _ := history.Current(); !reflect.DeepEqual(sessionNames(current.Sessions), step.want) {
+			t.Errorf("%s: current snapshot = %v, want %v", step.name, sessionNames(current.Sessions), step.want)
+		}
+
+		sessions, err := LoadSessionsData(key)
+		if err != nil {
+			t.Fatalf("%s: LoadSessionsData: %v", step.name, err)
+		}
+		if got := sessionNames(sessions); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: list = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	useTempDir(t)
+	key := ListKey{}
+	for i := range maxHistory + 10 {
+		saveNames(t, key, fmt.Sprintf("s%d", i))
+	}
+
+	history, err := LoadHistory(key)
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	if len(history.Snapshots) != maxHistory || history.Position != maxHistory-1 {
+		t.Fatalf("history has %d snapshots at %d, want %d at the last", len(history.Snapshots), history.Position, maxHistory)
+	}
+	if oldest := history.Snapshots[0].Sessions[0].Name; oldest != "s10" {
+		t.Errorf("oldest snapshot = %s, want s10", oldest)
+	}
+}
+
+func saveNames(t *testing.T, key ListKey, names ...string) {
+	t.Helper()
+	sessions := make([]SessionData, len(names))
+	for i, name := range names {
+		sessions[i] = SessionData{Name: name}
+	}
+	if err := SaveSessionsData(key, sessions, CauseTUISave); err != nil {
+		t.Fatalf("SaveSessionsData(%v): %v", names, err)
+	}
+}
+
+func sessionNames(sessions []SessionData) []string {
+	names := make([]string, len(sessions))
+	for i, session := range sessions {
+		names[i] = session.Name
+	}
+	return names
+}
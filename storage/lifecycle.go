@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntryStatus is the lifecycle state of a list entry
+type EntryStatus string
+
+const (
+	// StatusActive entries take part in navigation
+	StatusActive EntryStatus = "active"
+	// StatusHidden entries were hidden by the user, e.g. with d in the UI
+	StatusHidden EntryStatus = "hidden"
+	// StatusMissing entries couldn't be found in tmux. They become active
+	// again when the session reappears.
+	StatusMissing EntryStatus = "missing"
+	// StatusArchived entries were killed with --tombstone, or were missing
+	// for longer than TombstoneConfig.ArchiveMissingAfter
+	StatusArchived EntryStatus = "archived"
+)
+
+// EntryStatuses lists the states in display order
+var EntryStatuses = []EntryStatus{StatusActive, StatusHidden, StatusMissing, StatusArchived}
+
+// ParseEntryStatus validates a state name
+func ParseEntryStatus(name string) (EntryStatus, error) {
+	for _, status := range EntryStatuses {
+		if string(status) == name {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown status '%s', expected active, hidden, missing or archived", name)
+}
+
+// State returns the entry's status, entries without one are active
+func (s SessionData) State() EntryStatus {
+	if s.Status == "" {
+		return StatusActive
+	}
+	return s.Status
+}
+
+// Active reports whether the entry takes part in navigation
+func (s SessionData) Active() bool {
+	return s.State() == StatusActive
+}
+
+// SetState moves the entry to status, recording when it changed
+// Active entries are stored without a status or timestamp.
+func (s *SessionData) SetState(status EntryStatus, now time.Time) {
+	if s.State() == status {
+		return
+	}
+	if status == StatusActive {
+		s.Status = ""
+		s.StatusSince = time.Time{}
+		return
+	}
+	s.Status = status
+	s.StatusSince = now.UTC().Truncate(time.Second)
+}
+
+// Duration is a time.Duration written as text, e.g. "36h" or "30d"
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler, using days when exact
+func (d Duration) MarshalText() ([]byte, error) {
+	day := 24 * time.Hour
+	if d != 0 && time.Duration(d)%day == 0 {
+		return []byte(fmt.Sprintf("%dd", time.Duration(d)/day)), nil
+	}
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting Go
+// durations plus a "d" suffix for days, and "0" to disable
+func (d *Duration) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid duration '%s'", value)
+		}
+		*d = Duration(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("invalid duration '%s', use e.g. 36h or 30d", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// TombstoneConfig controls how long entries that aren't active are kept
+// Zero durations disable the step.
+type TombstoneConfig struct {
+	// ArchiveMissingAfter archives entries missing from tmux for this long
+	ArchiveMissingAfter Duration `json:"archive_missing_after" toml:"archive_missing_after" yaml:"archive_missing_after"`
+	// RemoveArchivedAfter removes entries archived for this long
+	RemoveArchivedAfter Duration `json:"remove_archived_after" toml:"remove_archived_after" yaml:"remove_archived_after"`
+}
+
+// Resurrect makes missing entries active again when their session is live,
+// see FollowRenames. Returns true if any entry changed.
+func Resurrect(sessions []SessionData, live map[string]LiveSession, now time.Time) bool {
+	liveNames := make(map[string]bool, len(live))
+	for _, session := range live {
+		liveNames[session.Name] = true
+	}
+
+	changed := false
+	for i := range sessions {
+		session := &sessions[i]
+		if session.State() != StatusMissing {
+			continue
+		}
+		if liveNames[session.Name] {
+			session.SetState(StatusActive, now)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ExpireTombstones archives entries missing for too long and removes
+// entries archived for too long. Entries without a timestamp, e.g. from
+// before statuses were recorded, start their clock now. Returns the updated
+// list and whether it changed.
+func ExpireTombstones(sessions []SessionData, cfg TombstoneConfig, now time.Time) ([]SessionData, bool) {
+	changed := false
+	kept := sessions[:0:0]
+	for _, session := range sessions {
+		state := session.State()
+		if state == StatusMissing || state == StatusArchived {
+			if session.StatusSince.IsZero() {
+				session.StatusSince = now.UTC().Truncate(time.Second)
+				changed = true
+			}
+			age := now.Sub(session.StatusSince)
+
+			if state == StatusMissing && cfg.ArchiveMissingAfter > 0 && age >= time.Duration(cfg.ArchiveMissingAfter) {
+				session.SetState(StatusArchived, now)
+				changed = true
+			} else if state == StatusArchived && cfg.RemoveArchivedAfter > 0 && age >= time.Duration(cfg.RemoveArchivedAfter) {
+				changed = true
+				continue
+			}
+		}
+		kept = append(kept, session)
+	}
+	return kept, changed
+}
+
+// migrateDeletedToStatus replaces the deleted flag of version 2 lists with a
+// status. Version 2 couldn't tell hidden entries from missing ones, hidden
+// is the safe choice as it is never changed automatically.
+func migrateDeletedToStatus(data []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	sessions, _ := doc["sessions"].([]any)
+	for _, entry := range sessions {
+		session, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		if deleted, _ := session["deleted"].(bool); deleted {
+			session["status"] = string(StatusHidden)
+		}
+		delete(session, "deleted")
+	}
+	doc["version"] = versionStatus
+	return json.Marshal(doc)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpireTombstones(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	cfg := TombstoneConfig{ArchiveMissingAfter: Duration(30 * day), RemoveArchivedAfter: Duration(90 * day)}
+
+	tests := []struct {
+		name    string
+		entry   SessionData
+		cfg     TombstoneConfig
+		want    EntryStatus
+		since   time.Time
+		removed bool
+		changed bool
+	}{
+		{name: "active", entry: SessionData{Name: "api"}, cfg: cfg, want: StatusActive},
+		{name: "hidden stays hidden", entry: SessionData{Name: "api", Status: StatusHidden, StatusSince: now.Add(-365 * day)}, cfg: cfg, want: StatusHidden, since: now.Add(-365 * day)},
+		{name: "missing recently", entry: SessionData{Name: "api", Status: StatusMissing, StatusSince: now.Add(-29 * day)}, cfg: cfg, want: StatusMissing, since: now.Add(-29 * day)},
+		{name: "missing too long", entry: SessionData{Name: "api", Status: StatusMissing, StatusSince: now.Add(-30 * day)}, cfg: cfg, want: StatusArchived, since: now, changed: true},
+		{name: "missing without a timestamp", entry: SessionData{Name: "api", Status: StatusMissing}, cfg: cfg, want: StatusMissing, since: now, changed: true},
+		{name: "archived recently", entry: SessionData{Name: "api", Status: StatusArchived, StatusSince: now.Add(-89 * day)}, cfg: cfg, want: StatusArchived, since: now.Add(-89 * day)},
+		{name: "archived too long", entry: SessionData{Name: "api", Status: StatusArchived, StatusSince: now.Add(-90 * day)}, cfg: cfg, removed: true, changed: true},
+		{name: "archiving disabled", entry: SessionData{Name: "api", Status: StatusMissing, StatusSince: now.Add(-365 * day)}, want: StatusMissing, since: now.Add(-365 * day)},
+		{name: "removing disabled", entry: SessionData{Name: "api", Status: StatusArchived, StatusSince: now.Add(-365 * day)}, want: StatusArchived, since: now.Add(-365 * day)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := []SessionData{tt.entry}
+			kept, changed := ExpireTombstones(sessions, tt.cfg, now)
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(sessions[0], tt.entry) {
+				t.Errorf("the list passed in was changed to %+v", sessions[0])
+			}
+			if tt.removed {
+				if len(kept) != 0 {
+					t.Errorf("kept %+v, want it removed", kept)
+				}
+				return
+			}
+			if len(kept) != 1 {
+				t.Fatalf("kept %+v, want the entry", kept)
+			}
+			if kept[0].State() != tt.want || !kept[0].StatusSince.Equal(tt.since) {
+				t.Errorf("entry is %s since %v, want %s since %v", kept[0].State(), kept[0].StatusSince, tt.want, tt.since)
+			}
+		})
+	}
+}
+
+func TestResurrect(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-time.Hour)
+	sessions := []SessionData{
+		{Name: "api", Status: StatusMissing, StatusSince: since},
+		{Name: "web", Status: StatusMissing, StatusSince: since},
+		{Name: "db", Status: StatusHidden, StatusSince: since},
+		{Name: "old", Status: StatusArchived, StatusSince: since},
+	}
+	live := map[string]LiveSession{"$1": {Name: "api"}, "$2": {Name: "db"}, "$3": {Name: "old"}}
+
+	if !Resurrect(sessions, live, now) {
+		t.Fatal("Resurrect reported no change")
+	}
+	want := []EntryStatus{StatusActive, StatusMissing, StatusHidden, StatusArchived}
+	for i, status := range want {
+		if sessions[i].State() != status {
+			t.Errorf("%s is %s, want %s", sessions[i].Name, sessions[i].State(), status)
+		}
+	}
+	if !sessions[0].StatusSince.IsZero() {
+		t.Errorf("active entry kept its timestamp %v", sessions[0].StatusSince)
+	}
+	if Resurrect(sessions, live, now) {
+		t.Error("second Resurrect reported a change")
+	}
+}
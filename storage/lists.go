@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultList is the name of the list kept at the top of the state directory
+const DefaultList = "default"
+
+// listsDir is the state subdirectory holding named lists, each with the same
+// layout as the state directory itself: rolo.json and servers/
+const listsDir = "lists"
+
+// ListKey identifies a session list: a named list of a tmux server
+// The zero value is the default list of the default server.
+type ListKey struct {
+	Server string
+	List   string
+}
+
+func (k ListKey) server() string {
+	if k.Server == "" {
+		return DefaultServer
+	}
+	return k.Server
+}
+
+func (k ListKey) list() string {
+	if k.List == "" {
+		return DefaultList
+	}
+	return k.List
+}
+
+// String describes the key for messages, e.g. "work (server t1)"
+func (k ListKey) String() string {
+	if k.server() == DefaultServer {
+		return k.list()
+	}
+	return fmt.Sprintf("%s (server %s)", k.list(), k.server())
+}
+
+var listNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateListName reports whether name can be used for a list
+func ValidateListName(name string) error {
+	if !listNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid list name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// GetListPath returns the path to a session list, in whichever supported
+// format it is kept
+func GetListPath(key ListKey) (string, error) {
+	if key.list() == DefaultList {
+		return GetServerJSONPath(key.server())
+	}
+	if err := ValidateListName(key.list()); err != nil {
+		return "", err
+	}
+	if key.server() == DefaultServer {
+		return formatFile(stateFile(listsDir, key.list(), "rolo"))
+	}
+	return formatFile(stateFile(listsDir, key.list(), "servers", serverFileName(key.server())))
+}
+
+// ListNames returns the default list followed by the named lists, sorted
+func ListNames() ([]string, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(paths.StateDir, listsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read lists: %w", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateListName(entry.Name()) == nil && entry.Name() != DefaultList {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultList}, names...), nil
+}
+
+// ListExists reports whether a list has been created
+func ListExists(name string) (bool, error) {
+	if name == "" || name == DefaultList {
+		return true, nil
+	}
+	if err := ValidateListName(name); err != nil {
+		return false, err
+	}
+
+	dir, err := stateFile(listsDir, name)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// CreateList creates an empty named list, or one holding sessions
+func CreateList(key ListKey, sessions []SessionData) error {
+	exists, err := ListExists(key.list())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("list '%s' already exists", key.list())
+	}
+
+	if sessions == nil {
+		sessions = []SessionData{}
+	}
+	return SaveSessionsData(key, sessions, CauseListNew)
+}
+
+// RemoveList deletes a named list for every tmux server
+func RemoveList(name string) error {
+	if name == "" || name == DefaultList {
+		return fmt.Errorf("the %s list can't be removed", DefaultList)
+	}
+	exists, err := ListExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("list '%s' doesn't exist", name)
+	}
+
+	dir, err := stateFile(listsDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove list '%s': %w", name, err)
+	}
+	return nil
+}
+
+// activeListFile is the state file remembering the active list
+const activeListFile = "active_list.json"
+
+// activeListState is the contents of activeListFile
+type activeListState struct {
+	// List is the active list, "" for the default one
+	List string `json:"list"`
+}
+
+// LoadActiveList returns the list made active with `rolo list use`, "" for
+// the default list. Reports false if no list was ever made active, in which
+// case a config written by an older version may still name one, see
+// Config.ActiveList.
+func LoadActiveList() (string, bool, error) {
+	path, err := stateFile(activeListFile)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	var state activeListState
+	err = readWithBackup(path, func(data []byte) error {
+		state = activeListState{}
+		return json.Unmarshal(data, &state)
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to load the active list: %w", err)
+	}
+	return state.List, true, nil
+}
+
+// SetActiveList remembers name as the active list. It is kept in the state
+// directory rather than the config, which rolo doesn't rewrite.
+func SetActiveList(name string) error {
+	return updateActiveList(func(string) string {
+		return name
+	})
+}
+
+// ForgetActiveList makes the default list active again if name is the
+// active list, e.g. once it has been removed
+func ForgetActiveList(name string) error {
+	return updateActiveList(func(active string) string {
+		if active == name {
+			return ""
+		}
+		return active
+	})
+}
+
+// updateActiveList replaces the active list with the one update returns,
+// holding the state file's lock throughout
+func updateActiveList(update func(active string) string) error {
+	path, err := stateFile(activeListFile)
+	if err != nil {
+		return err
+	}
+	lock, err := lockPath(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	active, _, err := LoadActiveList()
+	if err != nil {
+		return err
+	}
+	name := update(active)
+	if name == DefaultList {
+		name = ""
+	}
+
+	data, err := json.MarshalIndent(activeListState{List: name}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal the active list: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the active list: %w", err)
+	}
+	return nil
+}
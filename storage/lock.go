@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileLock is an advisory lock on a lock file next to a data file. It
+// serialises load-modify-save sequences between rolo processes, e.g. when a
+// key repeat fires several `rolo next` at once.
+type FileLock struct {
+	file *os.File
+	// path is the lock file, so holders can tell which lock they have
+	path string
+}
+
+// LockSessions takes the lock guarding a session list,
+// blocking until it is available. Release it with Unlock.
+//
+// Locks are per open file, so a process must not take the same lock twice.
+// SaveSessionsData doesn't lock by itself, callers hold the lock across the
+// whole load-modify-save sequence instead.
+func LockSessions(key ListKey) (*FileLock, error) {
+	path, err := GetListPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return lockPath(path + ".lock")
+}
+
+// LockConfig takes the lock guarding the config file, blocking until it is
+// available. Release it with Unlock. The lock file is kept in the state
+// directory. Like SaveSessionsData, SaveConfig doesn't lock by itself,
+// callers hold the lock across the whole load-modify-save sequence.
+func LockConfig() (*FileLock, error) {
+	configPath, err := GetConfigSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := configSideFile(configPath, ".lock")
+	if err != nil {
+		return nil, err
+	}
+	return lockPath(path)
+}
+
+// lockPath opens (creating if needed) and locks the file at path
+func lockPath(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := flock(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return &FileLock{file: file, path: path}, nil
+}
+
+// holds reports whether the lock is the held lock file at path
+func (l *FileLock) holds(path string) bool {
+	return l != nil && l.file != nil && l.path == path
+}
+
+// Unlock releases the lock, it is safe to call more than once or on nil
+func (l *FileLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := funlock(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
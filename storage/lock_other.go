@@ -0,0 +1,15 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// Advisory locking is only implemented on unix, where tmux runs
+
+func flock(file *os.File) error {
+	return nil
+}
+
+func funlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockIsExclusive(t *testing.T) {
+	tests := []struct {
+		name string
+		lock func() (*FileLock, error)
+	}{
+		{name: "default list", lock: func() (*FileLock, error) { return LockSessions(ListKey{}) }},
+		{name: "server list", lock: func() (*FileLock, error) { return LockSessions(ListKey{Server: "work"}) }},
+		{name: "named list", lock: func() (*FileLock, error) { return LockSessions(ListKey{List: "oncall"}) }},
+		{name: "config", lock: LockConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempDir(t)
+			held, err := tt.lock()
+			if err != nil {
+				t.Fatalf("lock: %v", err)
+			}
+
+			acquired := make(chan *FileLock, 1)
+			go func() {
+				lock, err := tt.lock()
+				if err != nil {
+					t.Errorf("second lock: %v", err)
+				}
+				acquired <- lock
+			}()
+
+			select {
+			case lock := <-acquired:
+				lock.Unlock()
+				t.Fatal("the lock was taken twice")
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			if err := held.Unlock(); err != nil {
+				t.Fatalf("Unlock: %v", err)
+			}
+			select {
+			case lock := <-acquired:
+				lock.Unlock()
+			case <-time.After(5 * time.Second):
+				t.Fatal("the lock wasn't released")
+			}
+
+			// Unlocking again, or a lock never taken, does nothing
+			if err := held.Unlock(); err != nil {
+				t.Errorf("second Unlock: %v", err)
+			}
+			var none *FileLock
+			if err := none.Unlock(); err != nil {
+				t.Errorf("Unlock on nil: %v", err)
+			}
+		})
+	}
+}
+
+func TestLocksAreIndependent(t *testing.T) {
+	useTempDir(t)
+	keys := []ListKey{{}, {Server: "work"}, {List: "oncall"}, {Server: "work", List: "oncall"}}
+
+	done := make(chan error, 1)
+	go func() {
+		for _, key := range keys {
+			lock, err := LockSessions(key)
+			if err != nil {
+				done <- err
+				return
+			}
+			defer lock.Unlock()
+		}
+		config, err := LockConfig()
+		if err == nil {
+			config.Unlock()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("lock: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("locks of different files blocked each other")
+	}
+}
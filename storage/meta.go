@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SessionMeta describes what a session is for. Every field is optional.
+type SessionMeta struct {
+	// Dir is the directory the session is created in when rolo starts it
+	Dir string `json:"dir,omitempty" toml:"dir,omitempty" yaml:"dir,omitempty"`
+	// Tags are free-form labels
+	Tags []string `json:"tags,omitempty" toml:"tags,omitempty" yaml:"tags,omitempty"`
+	// Notes is free-form text
+	Notes string `json:"notes,omitempty" toml:"notes,omitempty" yaml:"notes,omitempty"`
+	// Pinned sessions stay in the list when it is repopulated from tmux
+	Pinned bool `json:"pinned,omitempty" toml:"pinned,omitempty" yaml:"pinned,omitempty"`
+	// Alias is shown instead of the session name
+	Alias string `json:"alias,omitempty" toml:"alias,omitempty" yaml:"alias,omitempty"`
+	// Color is a tmux colour, e.g. "red", "colour33" or "#89b4fa"
+	Color string `json:"color,omitempty" toml:"color,omitempty" yaml:"color,omitempty"`
+	// Group names the group the session belongs to, e.g. "backend"
+	Group string `json:"group,omitempty" toml:"group,omitempty" yaml:"group,omitempty"`
+}
+
+// MetaFields are the field names accepted by SetMeta, in display order
+var MetaFields = []string{"alias", "dir", "tags", "notes", "pinned", "color", "group"}
+
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|colou?r[0-9]{1,3}|[a-z]+)$`)
+
+// DisplayName returns the alias of the session, or its name
+func (s SessionData) DisplayName() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// HasTag reports whether the session is tagged with tag
+func (s SessionData) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMeta sets a metadata field from its text form, an empty value clears it
+// Tags are comma separated, and a leading ~ in dir is expanded.
+func (s *SessionData) SetMeta(field, value string) error {
+	value = strings.TrimSpace(value)
+	switch field {
+	case "alias":
+		s.Alias = value
+	case "dir":
+		if strings.HasPrefix(value, "~") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			value = filepath.Join(home, strings.TrimPrefix(value, "~"))
+		}
+		s.Dir = value
+	case "tags":
+		s.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !s.HasTag(tag) {
+				s.Tags = append(s.Tags, tag)
+			}
+		}
+	case "notes":
+		s.Notes = value
+	case "pinned":
+		if value == "" {
+			s.Pinned = false
+			return nil
+		}
+		pinned, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for pinned: '%s', use true or false", value)
+		}
+		s.Pinned = pinned
+	case "color":
+		if value != "" && !colorPattern.MatchString(value) {
+			return fmt.Errorf("invalid colour '%s', use a tmux colour such as red, colour33 or #89b4fa", value)
+		}
+		s.Color = value
+	case "group":
+		s.Group = value
+	default:
+		return fmt.Errorf("unknown field '%s', expected one of %s", field, strings.Join(MetaFields, ", "))
+	}
+	return nil
+}
+
+// GetMeta returns the text form of a metadata field, as accepted by SetMeta
+func (s SessionData) GetMeta(field string) string {
+	switch field {
+	case "alias":
+		return s.Alias
+	case "dir":
+		return s.Dir
+	case "tags":
+		return strings.Join(s.Tags, ",")
+	case "notes":
+		return s.Notes
+	case "pinned":
+		if s.Pinned {
+			return "true"
+		}
+		return ""
+	case "color":
+		return s.Color
+	case "group":
+		return s.Group
+	}
+	return ""
+}
+
+// ParseMetaAssignment splits "field=value" as used by `rolo meta set`
+func ParseMetaAssignment(assignment string) (string, string, error) {
+	field, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected field=value, got '%s'", assignment)
+	}
+	return strings.TrimSpace(field), value, nil
+}
+
+// Repopulate rebuilds a list from the live sessions, given in tmux order,
+// keeping the metadata of sessions that were already in the list. Pinned
+// sessions that aren't live are kept at the end.
+func Repopulate(previous, live []SessionData) []SessionData {
+	used := make([]bool, len(previous))
+	find := func(session SessionData) int {
+		for i, p := range previous {
+			if !used[i] && sameID(p.ID, p.Created, session.ID, session.Created) {
+				return i
+			}
+		}
+		for i, p := range previous {
+			if !used[i] && p.Name == session.Name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	sessions := make([]SessionData, 0, len(live))
+	for _, session := range live {
+		if i := find(session); i != -1 {
+			used[i] = true
+			session.SessionMeta = previous[i].SessionMeta
+		}
+		sessions = append(sessions, session)
+	}
+
+	for i, p := range previous {
+		if !used[i] && p.Pinned {
+			sessions = append(sessions, p)
+		}
+	}
+	return sessions
+}
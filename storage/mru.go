@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxVisits is the number of sessions kept in a server's MRU log
+const maxVisits = 50
+
+// ErrNoPrevious is returned by PreviousSession when no other session in the
+// MRU log is still running
+var ErrNoPrevious = errors.New("no previous session to switch to")
+
+// Visit is a session rolo switched to or away from
+type Visit struct {
+	// ID is the tmux session id ($N), used to follow renames
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Created is when the session with ID was created, see sameID
+	Created time.Time `json:"created,omitzero"`
+	Time    time.Time `json:"time"`
+	// Count is how many times rolo switched to the session, for frecency
+	Count int `json:"count,omitempty"`
+}
+
+// MRU is the most-recently-used log of a tmux server's sessions
+type MRU struct {
+	// Visits holds each session once, most recent first
+	Visits []Visit `json:"visits"`
+}
+
+// GetMRUPath returns the path of a tmux server's MRU log
+func GetMRUPath(server string) (string, error) {
+	if server == "" {
+		server = DefaultServer
+	}
+	return stateFile("mru", serverFileName(server)+".json")
+}
+
+// LoadMRU reads the MRU log of a tmux server
+// Returns an empty log if nothing has been recorded yet
+func LoadMRU(server string) (*MRU, error) {
+	path, err := GetMRUPath(server)
+	if err != nil {
+		return nil, err
+	}
+	return loadMRU(path)
+}
+
+func loadMRU(path string) (*MRU, error) {
+	mru := &MRU{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return mru, nil
+	}
+
+	err := readWithBackup(path, func(data []byte) error {
+		mru = &MRU{}
+		return json.Unmarshal(data, mru)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MRU log: %w", err)
+	}
+	return mru, nil
+}
+
+func saveMRU(path string, mru *MRU) error {
+	data, err := json.MarshalIndent(mru, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal MRU log: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write MRU log: %w", err)
+	}
+	return nil
+}
+
+// updateMRU loads a server's MRU log, applies update and saves the log if
+// update reports a change, holding the log's lock throughout
+func updateMRU(server string, update func(mru *MRU) bool) error {
+	path, err := GetMRUPath(server)
+	if err != nil {
+		return err
+	}
+	lock, err := lockPath(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	mru, err := loadMRU(path)
+	if err != nil {
+		return err
+	}
+	if !update(mru) {
+		return nil
+	}
+	return saveMRU(path, mru)
+}
+
+// RecordSwitch records a switch from one session to another, leaving to
+// as the most recent visit and from just before it. from may be left empty
+// when rolo wasn't in a session, e.g. when attaching from outside tmux.
+func RecordSwitch(server string, from, to Visit) error {
+	return updateMRU(server, func(mru *MRU) bool {
+		if from.Name != "" && !from.same(to) {
+			mru.visit(from)
+		}
+		mru.visit(to)
+		mru.Visits[0].Count++
+		return true
+	})
+}
+
+// PreviousSession returns the most recent visit other than current whose
+// session is still live, see FollowRenames. Visits of
+// sessions that no longer exist are dropped from the log along the way, and
+// renamed sessions are followed. Returns ErrNoPrevious if there is none.
+func PreviousSession(server string, current Visit, live map[string]LiveSession) (Visit, error) {
+	resolve := visitResolver(live)
+
+	var previous Visit
+	found := false
+	err := updateMRU(server, func(mru *MRU) bool {
+		changed := false
+		kept := mru.Visits[:0]
+		for _, visit := range mru.Visits {
+			resolved, ok := resolve(visit)
+			if !ok {
+				// Gone, like entries skipped by next and prev
+				changed = true
+				continue
+			}
+			changed = changed || resolved != visit
+			visit = resolved
+			kept = append(kept, visit)
+
+			if !found && !visit.same(current) {
+				previous, found = visit, true
+			}
+		}
+		mru.Visits = kept
+		return changed
+	})
+	if err != nil {
+		return Visit{}, err
+	}
+	if !found {
+		return Visit{}, ErrNoPrevious
+	}
+	return previous, nil
+}
+
+// visitResolver returns a function that finds the live session of a
+// visit, see FollowRenames, by name first and then by id to follow renames.
+// It reports false for sessions that no longer exist.
+func visitResolver(live map[string]LiveSession) func(Visit) (Visit, bool) {
+	liveIDByName := make(map[string]string, len(live))
+	for id, session := range live {
+		liveIDByName[session.Name] = id
+	}
+
+	return func(visit Visit) (Visit, bool) {
+		if id, ok := liveIDByName[visit.Name]; ok {
+			if created := live[id].Created; visit.ID != id || !visit.Created.Equal(created) {
+				visit.ID, visit.Created = id, created
+			}
+			return visit, true
+		}
+		if session, ok := live[visit.ID]; ok && sameID(visit.ID, visit.Created, visit.ID, session.Created) {
+			visit.Name = session.Name
+			return visit, true
+		}
+		return visit, false
+	}
+}
+
+// stampVisit sets the time of a visit to now unless it has one
+func stampVisit(v Visit) Visit {
+	if v.Time.IsZero() {
+		v.Time = time.Now()
+	}
+	v.Time = v.Time.UTC().Truncate(time.Second)
+	return v
+}
+
+// Find returns the visit of an entry's session, by id first and then by
+// name
+func (m *MRU) Find(session SessionData) (Visit, bool) {
+	for _, visit := range m.Visits {
+		if sameID(visit.ID, visit.Created, session.ID, session.Created) {
+			return visit, true
+		}
+	}
+	for _, visit := range m.Visits {
+		if visit.Name == session.Name {
+			return visit, true
+		}
+	}
+	return Visit{}, false
+}
+
+// EntryVisit returns a visit of an entry's session, without a time
+func EntryVisit(session SessionData) Visit {
+	return Visit{ID: session.ID, Name: session.Name, Created: session.Created}
+}
+
+// Of reports whether a visit is of an entry's session
+func (v Visit) Of(session SessionData) bool {
+	return v.same(EntryVisit(session))
+}
+
+// same reports whether two visits are of the same session
+func (v Visit) same(other Visit) bool {
+	if sameID(v.ID, v.Created, other.ID, other.Created) {
+		return true
+	}
+	return v.Name == other.Name
+}
+
+// visit moves a session to the front of the log, keeping its count
+func (m *MRU) visit(v Visit) {
+	v = stampVisit(v)
+	visits := make([]Visit, 0, len(m.Visits)+1)
+	visits = append(visits, v)
+	for _, existing := range m.Visits {
+		if !existing.same(v) {
+			visits = append(visits, existing)
+		} else if visits[0].Count == 0 {
+			visits[0].Count = existing.Count
+		}
+	}
+	if len(visits) > maxVisits {
+		visits = visits[:maxVisits]
+	}
+	m.Visits = visits
+}
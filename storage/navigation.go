@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// maxNavigation is the number of visits kept in a client's navigation stack
+const maxNavigation = 50
+
+// outsideClient names the navigation stack used outside tmux
+const outsideClient = "terminal"
+
+var (
+	// ErrNoBack is returned by MoveNavigation at the start of the stack
+	ErrNoBack = errors.New("nothing to go back to")
+	// ErrNoForward is returned by MoveNavigation at the end of the stack
+	ErrNoForward = errors.New("nothing to go forward to")
+)
+
+// Navigation is the browser-style stack of sessions a tmux client visited
+// through rolo
+type Navigation struct {
+	// Visits holds the sessions in the order they were visited
+	Visits []Visit `json:"visits"`
+	// Position is the index of the current visit, visits after it can be
+	// gone forward to
+	Position int `json:"position"`
+	// Cycle is the run of next and prev steps in progress, if any
+	Cycle *Cycle `json:"cycle,omitempty"`
+}
+
+// Cycle is the order a run of next and prev steps goes through when the
+// strategy's order changes with every switch, as mru's does. It is kept for
+// as long as each step starts from the session the previous one switched
+// to, so repeated steps go round every session.
+type Cycle struct {
+	// Strategy and List are what the order was taken for
+	Strategy string `json:"strategy"`
+	List     string `json:"list"`
+	// Order holds the sessions in the order of the run
+	Order []Visit `json:"order"`
+	// At is the session the last step switched to
+	At Visit `json:"at"`
+}
+
+// GetNavigationPath returns the path of a tmux client's navigation stack.
+// Clients are named by tty, "" is used for rolo running outside tmux.
+func GetNavigationPath(server, client string) (string, error) {
+	if server == "" {
+		server = DefaultServer
+	}
+	if client == "" {
+		client = outsideClient
+	}
+	return stateFile("navigation", serverFileName(server), serverFileName(client)+".json")
+}
+
+// LoadNavigation reads the navigation stack of a tmux client
+// Returns an empty stack if the client hasn't navigated yet
+func LoadNavigation(server, client string) (*Navigation, error) {
+	path, err := GetNavigationPath(server, client)
+	if err != nil {
+		return nil, err
+	}
+	return loadNavigation(path)
+}
+
+func loadNavigation(path string) (*Navigation, error) {
+	nav := &Navigation{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nav, nil
+	}
+
+	err := readWithBackup(path, func(data []byte) error {
+		nav = &Navigation{}
+		return json.Unmarshal(data, nav)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load navigation stack: %w", err)
+	}
+	if nav.Position < 0 || nav.Position >= len(nav.Visits) {
+		nav.Position = len(nav.Visits) - 1
+	}
+	return nav, nil
+}
+
+func saveNavigation(path string, nav *Navigation) error {
+	data, err := json.MarshalIndent(nav, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal navigation stack: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write navigation stack: %w", err)
+	}
+	return nil
+}
+
+// updateNavigation loads a client's navigation stack, applies update and
+// saves the stack, holding the stack's lock throughout
+func updateNavigation(server, client string, update func(nav *Navigation) error) error {
+	path, err := GetNavigationPath(server, client)
+	if err != nil {
+		return err
+	}
+	lock, err := lockPath(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	nav, err := loadNavigation(path)
+	if err != nil {
+		return err
+	}
+	if err := update(nav); err != nil {
+		return err
+	}
+	return saveNavigation(path, nav)
+}
+
+// SaveCycle stores the run of next and prev steps of a tmux client, nil
+// when no run is in progress
+func SaveCycle(server, client string, cycle *Cycle) error {
+	return updateNavigation(server, client, func(nav *Navigation) error {
+		nav.Cycle = cycle
+		return nil
+	})
+}
+
+// PushNavigation records a new navigation from one session to another,
+// dropping the visits that could have been gone forward to. from may be
+// empty when the client wasn't in a session.
+func PushNavigation(server, client string, from, to Visit) error {
+	return updateNavigation(server, client, func(nav *Navigation) error {
+		if from.Name != "" {
+			nav.push(from)
+		}
+		nav.push(to)
+		return nil
+	})
+}
+
+// MoveNavigation moves steps visits back (negative) or forward (positive)
+// in a client's navigation stack and returns the visit moved to. live holds
+// the running sessions, see FollowRenames: visits of sessions that no
+// longer exist are dropped and renamed sessions are followed. If the
+// client is in a session other than the current visit, e.g. after
+// switching without rolo, that session is pushed first so it can be
+// returned to. Returns ErrNoBack or ErrNoForward at the ends of the stack.
+func MoveNavigation(server, client string, current Visit, steps int, live map[string]LiveSession) (Visit, error) {
+	var target Visit
+	err := updateNavigation(server, client, func(nav *Navigation) error {
+		nav.prune(live)
+		if current.Name != "" && (len(nav.Visits) == 0 || !nav.Visits[nav.Position].same(current)) {
+			nav.push(current)
+		}
+
+		position := nav.Position + steps
+		if position < 0 {
+			return ErrNoBack
+		}
+		if position >= len(nav.Visits) {
+			return ErrNoForward
+		}
+		nav.Position = position
+		target = nav.Visits[position]
+		return nil
+	})
+	return target, err
+}
+
+// push adds a visit after the current position, dropping the forward
+// branch. Visiting the current session again changes nothing, like
+// reloading a page.
+func (n *Navigation) push(v Visit) {
+	if len(n.Visits) > 0 {
+		if n.Visits[n.Position].same(v) {
+			n.Visits[n.Position] = stampVisit(v)
+			return
+		}
+		n.Visits = n.Visits[:n.Position+1]
+	}
+
+	n.Visits = append(n.Visits, stampVisit(v))
+	if len(n.Visits) > maxNavigation {
+		n.Visits = n.Visits[len(n.Visits)-maxNavigation:]
+	}
+	n.Position = len(n.Visits) - 1
+}
+
+// prune drops the visits of sessions that aren't live and follows renames,
+// keeping the position on the same visit where possible
+func (n *Navigation) prune(live map[string]LiveSession) {
+	resolve := visitResolver(live)
+
+	kept := n.Visits[:0]
+	position := n.Position
+	for i, visit := range n.Visits {
+		visit, ok := resolve(visit)
+		if !ok {
+			if i <= n.Position {
+				position--
+			}
+			continue
+		}
+		kept = append(kept, visit)
+	}
+
+	n.Visits = kept
+	n.Position = max(position, 0)
+	if len(kept) == 0 {
+		n.Position = -1
+	}
+}
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// SaveConfigValue sets the setting under a dotted key in the config file,
+// parsing text as SetConfigValue does, and returns the new value as
+// GetConfigValue renders it. Only that key is changed: the rest of the
+// file, comments and key order included, is kept as it is, and the file is
+// written through symlinks. Other settings aren't checked, so a config with
+// invalid values can still be fixed this way. Callers hold LockConfig.
+func SaveConfigValue(key, text string) (string, error) {
+	config := DefaultConfig()
+	if err := SetConfigValue(config, key, text); err != nil {
+		return "", err
+	}
+	value, err := configField(config, key)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := GetConfigValue(config, key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := EnsureConfigDir(); err != nil {
+		return "", err
+	}
+	configPath, err := GetConfigSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	target := configPath
+	if resolved, err := filepath.EvalSymlinks(configPath); err == nil {
+		target = resolved
+	}
+	side, err := configSideFile(configPath, "")
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	store, err := StoreFor(configPath)
+	if err != nil {
+		return "", err
+	}
+	patched, err := patchConfig(store, data, strings.Split(key, "."), value.Interface())
+	if err != nil {
+		return "", fmt.Errorf("failed to set %s in %s: %w", key, configPath, err)
+	}
+	// A patch that broke the file, e.g. a TOML table defined twice, is
+	// never written
+	if _, err := ValidateConfig(configPath, patched); err != nil {
+		return "", fmt.Errorf("failed to set %s in place, change it with 'rolo config edit': %w", key, err)
+	}
+
+	if err := writeFileAtomicAt(target, side, patched, 0644); err != nil {
+		return "", fmt.Errorf("failed to write config file: %w", err)
+	}
+	return rendered, nil
+}
+
+// patchConfig sets the key at path to value in a config document in the
+// format of store, leaving the rest of it as it is
+func patchConfig(store Store, data []byte, path []string, value any) ([]byte, error) {
+	switch store.Name() {
+	case "toml":
+		return patchTOML(data, path, value)
+	case "yaml":
+		return patchYAML(data, path, value)
+	case "json":
+		return patchJSON(data, path, value)
+	}
+	return nil, fmt.Errorf("can't edit %s files", store.Name())
+}
+
+// patchYAML edits the document as a node tree, which keeps comments and the
+// order of keys
+func patchYAML(data []byte, path []string, value any) ([]byte, error) {
+	root, err := yamlRoot(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := setYAMLKey(root.Content[0], path, value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// patchJSON edits the document as a YAML node tree, JSON being YAML, which
+// keeps the order of keys, and writes it back as indented JSON
+func patchJSON(data []byte, path []string, value any) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+	root, err := yamlRoot(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := setYAMLKey(root.Content[0], path, value); err != nil {
+		return nil, err
+	}
+
+	var compact bytes.Buffer
+	if err := writeJSONNode(&compact, root.Content[0]); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// yamlRoot parses a document whose root is a mapping, an empty document
+// becomes an empty mapping
+func yamlRoot(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected an object of settings")
+	}
+	return &doc, nil
+}
+
+// setYAMLKey sets the key at path under mapping, adding it and the mappings
+// leading to it at the end when missing. A replaced value keeps its
+// comments.
+func setYAMLKey(mapping *yaml.Node, path []string, value any) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		existing := mapping.Content[i+1]
+		if len(path) > 1 {
+			if existing.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s isn't an object", path[0])
+			}
+			return setYAMLKey(existing, path[1:], value)
+		}
+
+		replacement := &yaml.Node{}
+		if err := replacement.Encode(value); err != nil {
+			return err
+		}
+		replacement.HeadComment = existing.HeadComment
+		replacement.LineComment = existing.LineComment
+		replacement.FootComment = existing.FootComment
+		mapping.Content[i+1] = replacement
+		return nil
+	}
+
+	added := &yaml.Node{}
+	if err := added.Encode(value); err != nil {
+		return err
+	}
+	for i := len(path) - 1; i > 0; i-- {
+		added = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{yamlKey(path[i]), added}}
+	}
+	mapping.Content = append(mapping.Content, yamlKey(path[0]), added)
+	return nil
+}
+
+func yamlKey(name string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+}
+
+// writeJSONNode writes a node tree read from JSON, or encoded from Go
+// values, as compact JSON
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeJSONNode(buf, node.Content[0])
+	case yaml.AliasNode:
+		return writeJSONNode(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, node.Content[i].Value)
+			buf.WriteByte(':')
+			if err := writeJSONNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!str":
+			writeJSONString(buf, node.Value)
+		case "!!null":
+			buf.WriteString("null")
+		case "!!bool", "!!int", "!!float":
+			buf.WriteString(node.Value)
+		default:
+			return fmt.Errorf("unsupported value %q", node.Value)
+		}
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends the value with a newline
+	buf.Truncate(buf.Len() - 1)
+}
+
+// patchTOML edits the document line by line, there being no TOML library
+// that keeps comments: an existing key has its value replaced, keeping the
+// key as written and any comment after the value, and a missing key is
+// added to the end of its table, which is added when missing too
+func patchTOML(data []byte, path []string, value any) ([]byte, error) {
+	name := path[len(path)-1]
+	assignment, err := tomlAssignment(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(data)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	if line, ok := tomlKeyLines(data)[strings.Join(path, ".")]; ok {
+		start := line - 1
+		end := tomlValueEnd(lines, start)
+		key := tomlKey.FindString(strings.TrimLeft(lines[start], " \t"))
+		indent := lines[start][:len(lines[start])-len(strings.TrimLeft(lines[start], " \t"))]
+		_, rendered, _ := strings.Cut(assignment, "=")
+		replaced := indent + key + rendered + tomlComment(lines, start, end)
+		lines = append(lines[:start], append([]string{replaced}, lines[end+1:]...)...)
+		return []byte(strings.Join(lines, "\n") + "\n"), nil
+	}
+
+	table := strings.Join(path[:len(path)-1], ".")
+	at, found := tomlInsertLine(lines, table)
+	added := []string{assignment}
+	if !found {
+		// A new table goes at the end, after a blank line
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			added = append([]string{""}, added...)
+		}
+		added = append(added[:len(added)-1], "["+table+"]", assignment)
+		at = len(lines)
+	}
+	lines = append(lines[:at], append(added, lines[at:]...)...)
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// tomlAssignment renders "name = value" as the TOML encoder does
+func tomlAssignment(name string, value any) (string, error) {
+	data, err := tomlStore{}.Marshal(map[string]any{name: value})
+	if err != nil {
+		return "", err
+	}
+	assignment := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(assignment, "\n") {
+		return "", fmt.Errorf("%s can only be changed one key at a time in TOML", name)
+	}
+	return assignment, nil
+}
+
+// tomlValueEnd returns the last line of the value assigned on line start,
+// which only differs from start for values spanning lines, such as arrays
+func tomlValueEnd(lines []string, start int) int {
+	for end := start; end < len(lines); end++ {
+		var v map[string]any
+		if _, err := toml.Decode(strings.Join(lines[start:end+1], "\n"), &v); err == nil {
+			return end
+		}
+	}
+	return start
+}
+
+// tomlComment returns the comment after the value assigned on lines start
+// to end, with the space before it, "" if there is none. A # inside a
+// string leaves the value unparsable when cut there, which tells it apart.
+func tomlComment(lines []string, start, end int) string {
+	last := lines[end]
+	for i := strings.Index(last, "#"); i >= 0; {
+		var v map[string]any
+		value := append(append([]string{}, lines[start:end]...), last[:i])
+		if _, err := toml.Decode(strings.Join(value, "\n"), &v); err == nil {
+			return last[len(strings.TrimRight(last[:i], " \t")):]
+		}
+		next := strings.Index(last[i+1:], "#")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return ""
+}
+
+// tomlInsertLine returns the line a new key of table is inserted at: after
+// the last key of the table, or for the top level before the first table
+// and the comments leading up to it. Reports false if the table doesn't
+// exist.
+func tomlInsertLine(lines []string, table string) (int, bool) {
+	inTable := table == ""
+	found := inTable
+	at := -1
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		if m := tomlTable.FindStringSubmatch(text); m != nil || tomlArrayTable.MatchString(text) {
+			if inTable {
+				if at == -1 {
+					// No top level keys yet
+					at = i
+					for at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+						at--
+					}
+				}
+				return at, true
+			}
+			inTable = m != nil && tomlPath(m[1]) == table
+			if inTable {
+				found = true
+				at = i + 1
+			}
+			continue
+		}
+		if inTable && text != "" && !strings.HasPrefix(text, "#") {
+			at = i + 1
+		}
+	}
+	if at == -1 {
+		at = len(lines)
+	}
+	return at, found
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPatchConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		data  string
+		key   string
+		value any
+		want  string
+	}{
+		{
+			name:  "json existing key",
+			file:  "config.json",
+			data:  "{\n  \"wrap_around\": true,\n  \"strategy\": \"mru\"\n}\n",
+			key:   "strategy",
+			value: "frecency",
+			want:  "{\n  \"wrap_around\": true,\n  \"strategy\": \"frecency\"\n}\n",
+		},
+		{
+			name:  "json missing table",
+			file:  "config.json",
+			data:  "{\"strategy\": \"mru\"}",
+			key:   "status.show_index",
+			value: false,
+			want:  "{\n  \"strategy\": \"mru\",\n  \"status\": {\n    \"show_index\": false\n  }\n}\n",
+		},
+		{
+			name:  "json empty file",
+			file:  "config.json",
+			key:   "wrap_around",
+			value: true,
+			want:  "{\n  \"wrap_around\": true\n}\n",
+		},
+		{
+			name:  "toml existing key keeps its comment",
+			file:  "config.toml",
+			data:  "# Navigation\nstrategy = \"mru\" # changed often\n\n[status]\nshow_index = true\n",
+			key:   "strategy",
+			value: "frecency",
+			want:  "# Navigation\nstrategy = \"frecency\" # changed often\n\n[status]\nshow_index = true\n",
+		},
+		{
+			name:  "toml # inside a string",
+			file:  "config.toml",
+			data:  "strategy = \"a#b\"\n",
+			key:   "strategy",
+			value: "mru",
+			want:  "strategy = \"mru\"\n",
+		},
+		{
+			name:  "toml multi-line array",
+			file:  "config.toml",
+			data:  "[skip]\ntags = [\n  \"scratch\",\n  \"notes\",\n] # keep\nshells = []\n",
+			key:   "skip.tags",
+			value: []string{"play"},
+			want:  "[skip]\ntags = [\"play\"] # keep\nshells = []\n",
+		},
+		{
+			name:  "toml missing top level key",
+			file:  "config.toml",
+			data:  "wrap_around = true\n\n# Interface\n[status]\nshow_index = true\n",
+			key:   "strategy",
+			value: "mru",
+			want:  "wrap_around = true\nstrategy = \"mru\"\n\n# Interface\n[status]\nshow_index = true\n",
+		},
+		{
+			name:  "toml missing top level key before the first table",
+			file:  "config.toml",
+			data:  "# Interface\n[status]\nshow_index = true\n",
+			key:   "strategy",
+			value: "mru",
+			want:  "strategy = \"mru\"\n# Interface\n[status]\nshow_index = true\n",
+		},
+		{
+			name:  "toml missing key in a table",
+			file:  "config.toml",
+			data:  "[skip]\nshells = []\n\n[status]\nshow_index = true\n",
+			key:   "skip.tags",
+			value: []string{"play"},
+			want:  "[skip]\nshells = []\ntags = [\"play\"]\n\n[status]\nshow_index = true\n",
+		},
+		{
+			name:  "toml missing table",
+			file:  "config.toml",
+			data:  "strategy = \"mru\" # mine",
+			key:   "status.show_index",
+			value: false,
+			want:  "strategy = \"mru\" # mine\n\n[status]\nshow_index = false\n",
+		},
+		{
+			name:  "yaml existing key keeps comments",
+			file:  "config.yaml",
+			data:  "# Navigation\nstrategy: mru # changed often\nstatus:\n  show_index: true\n",
+			key:   "strategy",
+			value: "frecency",
+			want:  "# Navigation\nstrategy: frecency # changed often\nstatus:\n  show_index: true\n",
+		},
+		{
+			name:  "yaml missing key in a mapping",
+			file:  "config.yaml",
+			data:  "skip:\n  shells: [] # none\n",
+			key:   "skip.tags",
+			value: []string{"play"},
+			want:  "skip:\n  shells: [] # none\n  tags:\n    - play\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := StoreFor(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := patchConfig(store, []byte(tt.data), strings.Split(tt.key, "."), tt.value)
+			if err != nil {
+				t.Fatalf("patchConfig: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("patchConfig =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveConfigValueKeepsInvalidSettings(t *testing.T) {
+	dir := useTempDir(t)
+	path := filepath.Join(dir, "config.toml")
+	// A wrong type elsewhere in the file doesn't stop the key being set
+	writeFile(t, path, "wrap_around = \"yes\" # fix later\n")
+
+	value, err := SaveConfigValue("strategy", "mru")
+	if err != nil {
+		t.Fatalf("SaveConfigValue: %v", err)
+	}
+	if value != "mru" {
+		t.Errorf("SaveConfigValue = %q, want mru", value)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "wrap_around = \"yes\" # fix later\nstrategy = \"mru\"\n"; string(data) != want {
+		t.Errorf("config.toml =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestActiveList(t *testing.T) {
+	useTempDir(t)
+
+	steps := []struct {
+		name   string
+		set    string
+		forget string
+		want   string
+		chosen bool
+	}{
+		{name: "never chosen"},
+		{name: "use a list", set: "work", want: "work", chosen: true},
+		{name: "forget another list", forget: "play", want: "work", chosen: true},
+		{name: "forget the active list", forget: "work", chosen: true},
+		{name: "use the default list", set: DefaultList, chosen: true},
+	}
+	for _, step := range steps {
+		if step.set != "" {
+			if err := SetActiveList(step.set); err != nil {
+				t.Fatalf("%s: SetActiveList: %v", step.name, err)
+			}
+		}
+		if step.forget != "" {
+			if err := ForgetActiveList(step.forget); err != nil {
+				t.Fatalf("%s: ForgetActiveList: %v", step.name, err)
+			}
+		}
+		active, chosen, err := LoadActiveList()
+		if err != nil {
+			t.Fatalf("%s: LoadActiveList: %v", step.name, err)
+		}
+		if active != step.want || chosen != step.chosen {
+			t.Errorf("%s: LoadActiveList = %q, %v, want %q, %v", step.name, active, chosen, step.want, step.chosen)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Paths are the directories rolo keeps its files in
+type Paths struct {
+	// ConfigDir holds user settings: config.json
+	ConfigDir string
+	// StateDir holds data rolo rewrites as it runs: rolo.json, per-server
+	// lists, backups and lock files
+	StateDir string
+	// LegacyDir is where rolo kept everything before it followed the XDG
+	// base directory spec. Lists missing from StateDir are read from here.
+	// Empty when the directories were overridden.
+	LegacyDir string
+}
+
+var (
+	pathsOnce     sync.Once
+	resolvedPaths Paths
+	resolveErr    error
+	overrideDir   string
+)
+
+// SetConfigDir makes rolo keep all of its files in dir, as with --config
+// or $ROLO_CONFIG_DIR. Passing "" goes back to the default locations.
+// Tests use this to point the whole package at a temp directory.
+func SetConfigDir(dir string) {
+	overrideDir = dir
+	pathsOnce = sync.Once{}
+}
+
+// GetPaths returns the directories rolo uses, resolving them on first use:
+//
+//  1. the directory passed to SetConfigDir
+//  2. $ROLO_CONFIG_DIR
+//  3. $XDG_CONFIG_HOME/rolo and $XDG_STATE_HOME/rolo, defaulting to
+//     ~/.config/rolo and ~/.local/state/rolo
+func GetPaths() (Paths, error) {
+	pathsOnce.Do(func() {
+		resolvedPaths, resolveErr = resolvePaths()
+	})
+	return resolvedPaths, resolveErr
+}
+
+func resolvePaths() (Paths, error) {
+	dir := overrideDir
+	if dir == "" {
+		dir = os.Getenv("ROLO_CONFIG_DIR")
+	}
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return Paths{}, fmt.Errorf("failed to resolve config directory: %w", err)
+		}
+		return Paths{ConfigDir: abs, StateDir: abs}, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Paths{}, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return Paths{
+		ConfigDir: xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")),
+		StateDir:  xdgDir("XDG_STATE_HOME", filepath.Join(home, ".local", "state")),
+		LegacyDir: filepath.Join(home, ".config", "rolo"),
+	}, nil
+}
+
+// xdgDir returns rolo's directory under an XDG base directory variable
+// The spec says relative values are invalid and must be ignored
+func xdgDir(env, fallback string) string {
+	base := os.Getenv(env)
+	if base == "" || !filepath.IsAbs(base) {
+		base = fallback
+	}
+	return filepath.Join(base, "rolo")
+}
+
+// configFile returns the path of a file in the config directory
+func configFile(name string) (string, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(paths.ConfigDir, name), nil
+}
+
+// stateFile returns the path of a file in the state directory
+func stateFile(elem ...string) (string, error) {
+	paths, err := GetPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{paths.StateDir}, elem...)...), nil
+}
+
+// legacyFile returns where a state file used to live before the XDG split,
+// or "" if there is no separate legacy location
+func legacyFile(path string) string {
+	paths, err := GetPaths()
+	if err != nil || paths.LegacyDir == "" || paths.LegacyDir == paths.StateDir {
+		return ""
+	}
+	rel, err := filepath.Rel(paths.StateDir, path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(paths.LegacyDir, rel)
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ListIssue is an entry that makes a session list ambiguous: a session
+// listed more than once, which navigation only ever finds the first entry
+// of, or an entry without a name
+type ListIssue struct {
+	// Index is the position of the entry in the list
+	Index int
+	Name  string
+	// First is the position of the earlier entry with the same name, -1
+	// for entries without a name
+	First int
+}
+
+func (i ListIssue) String() string {
+	if i.First == -1 {
+		return fmt.Sprintf("entry %d: no name", i.Index+1)
+	}
+	return fmt.Sprintf("entry %d: '%s' duplicates entry %d", i.Index+1, i.Name, i.First+1)
+}
+
+// CheckSessions returns the duplicate and unnamed entries of a list
+func CheckSessions(sessions []SessionData) []ListIssue {
+	var issues []ListIssue
+	first := make(map[string]int, len(sessions))
+	for i, session := range sessions {
+		if strings.TrimSpace(session.Name) == "" {
+			issues = append(issues, ListIssue{Index: i, Name: session.Name, First: -1})
+			continue
+		}
+		if j, ok := first[session.Name]; ok {
+			issues = append(issues, ListIssue{Index: i, Name: session.Name, First: j})
+			continue
+		}
+		first[session.Name] = i
+	}
+	return issues
+}
+
+// RepairSessions drops unnamed entries and every entry but the first of a
+// session listed more than once, so the session keeps its first position.
+// Metadata only set on a dropped duplicate is carried over to the entry
+// that is kept, and a duplicate that is active makes it active. Returns the
+// repaired list and the entries that were dropped.
+func RepairSessions(sessions []SessionData) ([]SessionData, []ListIssue) {
+	issues := CheckSessions(sessions)
+	if len(issues) == 0 {
+		return sessions, nil
+	}
+
+	dropped := make(map[int]bool, len(issues))
+	repaired := append([]SessionData{}, sessions...)
+	for _, issue := range issues {
+		dropped[issue.Index] = true
+		if issue.First != -1 {
+			mergeDuplicate(&repaired[issue.First], sessions[issue.Index])
+		}
+	}
+
+	kept := make([]SessionData, 0, len(sessions)-len(issues))
+	for i, session := range repaired {
+		if !dropped[i] {
+			kept = append(kept, session)
+		}
+	}
+	return kept, issues
+}
+
+// mergeDuplicate fills the fields of kept that duplicate sets and kept
+// doesn't
+func mergeDuplicate(kept *SessionData, duplicate SessionData) {
+	if kept.ID == "" {
+		kept.ID, kept.Created = duplicate.ID, duplicate.Created
+	}
+	if !kept.Active() && duplicate.Active() {
+		kept.SetState(StatusActive, duplicate.StatusSince)
+	}
+	for _, field := range MetaFields {
+		if field == "tags" {
+			continue
+		}
+		if kept.GetMeta(field) == "" && duplicate.GetMeta(field) != "" {
+			// A hand-edited value SetMeta rejects is left behind
+			_ = kept.SetMeta(field, duplicate.GetMeta(field))
+		}
+	}
+	for _, tag := range duplicate.Tags {
+		if !kept.HasTag(tag) {
+			kept.Tags = append(kept.Tags, tag)
+		}
+	}
+}
+
+// issuesWarned records the lists already warned about by LoadSessionsData
+var issuesWarned sync.Map
+
+// issuesQuiet is set by commands that report list issues themselves
+var issuesQuiet bool
+
+// QuietIssues stops LoadSessionsData warning about duplicate and unnamed
+// entries, for commands that report them themselves
+func QuietIssues() {
+	issuesQuiet = true
+}
+
+// warnIssues reports the issues of a list once per process
+func warnIssues(path string, issues []ListIssue) {
+	if len(issues) == 0 || issuesQuiet {
+		return
+	}
+	if _, warned := issuesWarned.LoadOrStore(path, true); warned {
+		return
+	}
+	descriptions := make([]string, len(issues))
+	for i, issue := range issues {
+		descriptions[i] = issue.String()
+	}
+	warn("%s: %s; run 'rolo doctor --fix' to repair it", path, strings.Join(descriptions, ", "))
+}
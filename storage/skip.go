@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a regular expression written as text, e.g. "^scratch-"
+type Pattern struct {
+	*regexp.Regexp
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p Pattern) MarshalText() ([]byte, error) {
+	if p.Regexp == nil {
+		return []byte{}, nil
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *Pattern) UnmarshalText(text []byte) error {
+	re, err := regexp.Compile(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid pattern '%s': %v", text, err)
+	}
+	p.Regexp = re
+	return nil
+}
+
+// SkipConfig selects the entries next and prev pass over, on top of those
+// that aren't active. goto and the interactive UI still reach them.
+type SkipConfig struct {
+	// Names skips sessions whose name matches any of these patterns
+	Names []Pattern `json:"names" toml:"names" yaml:"names"`
+	// AttachedElsewhere skips sessions another client is attached to
+	AttachedElsewhere bool `json:"attached_elsewhere" toml:"attached_elsewhere" yaml:"attached_elsewhere"`
+	// Idle skips sessions whose panes are all at a shell prompt
+	Idle bool `json:"idle" toml:"idle" yaml:"idle"`
+	// Shells are the commands a pane counts as idle running
+	Shells []string `json:"shells" toml:"shells" yaml:"shells"`
+	// Tags skips entries with any of these tags
+	Tags []string `json:"tags" toml:"tags" yaml:"tags"`
+}
+
+// SkipFacts is what tmux knows about a live session that the skip rules
+// look at
+type SkipFacts struct {
+	// AttachedElsewhere is the number of clients attached to the session
+	// other than the one rolo runs in
+	AttachedElsewhere int
+	// Commands are the commands running in the session's panes, nil when
+	// unknown
+	Commands []string
+}
+
+// Skips reports whether next and prev pass over an entry. facts may be nil
+// when the session isn't live or tmux couldn't be asked, the rules needing
+// them then don't apply.
+func (c SkipConfig) Skips(session SessionData, facts *SkipFacts) bool {
+	for _, tag := range c.Tags {
+		if session.HasTag(tag) {
+			return true
+		}
+	}
+	for _, pattern := range c.Names {
+		if pattern.Regexp != nil && pattern.MatchString(session.Name) {
+			return true
+		}
+	}
+	if facts == nil {
+		return false
+	}
+	if c.AttachedElsewhere && facts.AttachedElsewhere > 0 {
+		return true
+	}
+	return c.Idle && len(facts.Commands) > 0 && c.allShells(facts.Commands)
+}
+
+// allShells reports whether every command is one of the configured shells.
+// Login shells may show up as e.g. "-zsh" and shells by their full path.
+func (c SkipConfig) allShells(commands []string) bool {
+	for _, command := range commands {
+		name := path.Base(strings.TrimPrefix(command, "-"))
+		shell := false
+		for _, candidate := range c.Shells {
+			if name == candidate {
+				shell = true
+				break
+			}
+		}
+		if !shell {
+			return false
+		}
+	}
+	return true
+}
@@ -1,102 +1,259 @@
 package storage
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
-// SessionData represents a session with its deleted state
+// SessionData represents a session with its lifecycle status and metadata
 type SessionData struct {
-	Name    string `json:"name"`
-	Deleted bool   `json:"deleted"`
+	// ID is the tmux session id ($N), used to follow renames
+	ID   string `json:"id,omitempty" toml:"id,omitempty" yaml:"id,omitempty"`
+	Name string `json:"name" toml:"name" yaml:"name"`
+	// Created is when the session with ID was created, see sameID
+	Created time.Time `json:"created,omitzero" toml:"created,omitempty" yaml:"created,omitempty"`
+	// Status is empty for active entries, see State
+	Status EntryStatus `json:"status,omitempty" toml:"status,omitempty" yaml:"status,omitempty"`
+	// StatusSince is when the entry last changed status
+	StatusSince time.Time `json:"status_since,omitzero" toml:"status_since,omitempty" yaml:"status_since,omitempty"`
+
+	SessionMeta `yaml:",inline"`
+}
+
+// LiveSession is a session running on a tmux server, as FollowRenames and
+// the other reconciling functions see it
+type LiveSession struct {
+	Name    string
+	Created time.Time
 }
 
+// sameID reports whether two stored session ids select the same session.
+// tmux numbers session ids from $0 again whenever the server restarts, so
+// an id only identifies a session together with when it was created, and
+// ids stored without a creation time are never trusted.
+func sameID(id string, created time.Time, otherID string, otherCreated time.Time) bool {
+	return id != "" && id == otherID && !created.IsZero() && created.Equal(otherCreated)
+}
+
+// FollowRenames reconciles stored sessions with the live sessions of a tmux
+// server, keyed by session id. Entries whose name is still live pick up the
+// current id, and entries whose id is live under a new, unclaimed name are
+// renamed. Ids of sessions that aren't live, or that were given to another
+// session after a server restart, are dropped so that only the name is used
+// for them. Returns true if any entry changed.
+func FollowRenames(sessions []SessionData, live map[string]LiveSession) bool {
+	liveIDByName := make(map[string]string, len(live))
+	for id, session := range live {
+		liveIDByName[session.Name] = id
+	}
+
+	// Names already represented in the list can't be the target of a rename
+	claimed := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		if _, ok := liveIDByName[session.Name]; ok {
+			claimed[session.Name] = true
+		}
+	}
+
+	changed := false
+	for i := range sessions {
+		session := &sessions[i]
+		if id, ok := liveIDByName[session.Name]; ok {
+			// Still live by name, the id may be new after a server restart
+			created := live[id].Created
+			if session.ID != id || !session.Created.Equal(created) {
+				session.ID, session.Created = id, created
+				changed = true
+			}
+			continue
+		}
+
+		if session.ID == "" {
+			continue
+		}
+		current, ok := live[session.ID]
+		if !ok || !sameID(session.ID, session.Created, session.ID, current.Created) {
+			session.ID, session.Created = "", time.Time{}
+			changed = true
+			continue
+		}
+		if !claimed[current.Name] {
+			session.Name = current.Name
+			claimed[current.Name] = true
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// DefaultServer is the key of the default tmux server's session list
+const DefaultServer = "default"
+
 // Config represents the rolo configuration settings
 type Config struct {
-	WrapAround bool `json:"wrap_around"`
+	// Schema points editors at the JSON Schema, see ConfigSchema
+	Schema     string       `json:"$schema,omitempty" toml:"$schema,omitempty" yaml:"$schema,omitempty"`
+	WrapAround bool         `json:"wrap_around" toml:"wrap_around" yaml:"wrap_around"`
+	Status     StatusConfig `json:"status" toml:"status" yaml:"status"`
+	// ActiveList is the list used when --list isn't given as older versions
+	// stored it, only read until `rolo list use` is run, see LoadActiveList
+	ActiveList string          `json:"active_list,omitempty" toml:"active_list,omitempty" yaml:"active_list,omitempty"`
+	Tombstones TombstoneConfig `json:"tombstones" toml:"tombstones" yaml:"tombstones"`
+	Skip       SkipConfig      `json:"skip" toml:"skip" yaml:"skip"`
+	// Strategy orders next and prev, one of StrategyNames
+	Strategy string `json:"strategy" toml:"strategy" yaml:"strategy"`
 }
 
-// GetConfigPath returns the path to the rolo config file
-func GetConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+// StatusConfig controls the output of `rolo status`
+// Styles use tmux style syntax, e.g. "fg=#cba6f7,bold"
+type StatusConfig struct {
+	Separator     string `json:"separator" toml:"separator" yaml:"separator"`
+	CurrentStyle  string `json:"current_style" toml:"current_style" yaml:"current_style"`
+	InactiveStyle string `json:"inactive_style" toml:"inactive_style" yaml:"inactive_style"`
+	ShowIndex     bool   `json:"show_index" toml:"show_index" yaml:"show_index"`
+	// MaxWidth limits the visible width of the output, 0 means unlimited
+	MaxWidth int `json:"max_width" toml:"max_width" yaml:"max_width"`
+}
+
+// DefaultConfig returns the settings used when config.json doesn't set them
+func DefaultConfig() *Config {
+	return &Config{
+		WrapAround: false,
+		Status: StatusConfig{
+			Separator:     " ",
+			CurrentStyle:  "fg=#cba6f7,bold",
+			InactiveStyle: "fg=#6c7086",
+			ShowIndex:     true,
+			MaxWidth:      0,
+		},
+		Tombstones: TombstoneConfig{
+			ArchiveMissingAfter: Duration(30 * 24 * time.Hour),
+			RemoveArchivedAfter: Duration(90 * 24 * time.Hour),
+		},
+		Skip: SkipConfig{
+			Names:  []Pattern{},
+			Shells: []string{"bash", "zsh", "fish", "sh", "dash", "ksh", "tcsh", "csh", "nu"},
+			Tags:   []string{"parked"},
+		},
+		Strategy: "stored",
 	}
-	return filepath.Join(home, ".config", "rolo", "rolo.txt"), nil
 }
 
-// GetConfigJSONPath returns the path to the rolo JSON config file
+// GetConfigPath returns the path to the legacy rolo.txt session list
+func GetConfigPath() (string, error) {
+	return configFile("rolo.txt")
+}
+
+// GetConfigJSONPath returns the path to the default server's session list,
+// rolo.json unless it is kept as rolo.toml or rolo.yaml
 func GetConfigJSONPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+	return formatFile(stateFile("rolo"))
+}
+
+// GetServerJSONPath returns the path to the session list for a tmux server
+// The default server keeps using rolo.json, other servers are stored under servers/
+func GetServerJSONPath(server string) (string, error) {
+	if server == "" || server == DefaultServer {
+		return GetConfigJSONPath()
 	}
-	return filepath.Join(home, ".config", "rolo", "rolo.json"), nil
+	return formatFile(stateFile("servers", serverFileName(server)))
 }
 
-// GetConfigSettingsPath returns the path to the rolo settings config file
-func GetConfigSettingsPath() (string, error) {
-	home, err := os.UserHomeDir()
+// formatFile resolves a path without extension to the file in whichever
+// format exists, see findFormat
+func formatFile(base string, err error) (string, error) {
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".config", "rolo", "config.json"), nil
+	return findFormat(base), nil
+}
+
+// serverFileName turns a server key, which may be a socket path, into a file name
+func serverFileName(server string) string {
+	name := strings.Trim(server, string(filepath.Separator))
+	replacer := strings.NewReplacer(string(filepath.Separator), "_", ":", "_", " ", "_")
+	return replacer.Replace(name)
+}
+
+// GetConfigSettingsPath returns the path to the rolo settings config file,
+// config.json unless it is kept as config.toml or config.yaml
+func GetConfigSettingsPath() (string, error) {
+	return formatFile(configFile("config"))
+}
+
+// configSideFile returns the path of a file rolo keeps about the config
+// file at configPath, named after it with suffix, e.g. its ".bak" copy.
+// These live in the state directory so that rolo only ever writes the
+// config itself to the config directory, which may be a dotfiles repo.
+func configSideFile(configPath, suffix string) (string, error) {
+	return stateFile(filepath.Base(configPath) + suffix)
 }
 
 // EnsureConfigDir creates the config directory if it doesn't exist
 func EnsureConfigDir() error {
-	configPath, err := GetConfigPath()
+	paths, err := GetPaths()
 	if err != nil {
 		return err
 	}
 	
-	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(paths.ConfigDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 	
 	return nil
 }
 
-// LoadSessionsData reads the session list with deleted state from the JSON config file
-// Falls back to old txt format if JSON doesn't exist
-func LoadSessionsData() ([]SessionData, error) {
-	jsonPath, err := GetConfigJSONPath()
+// LoadSessionsData reads a session list with the status of each entry
+// Lists written by older versions, including the default server's rolo.txt,
+// are read into the current schema. The file itself is only upgraded by the
+// next save, which holds the list's lock, or by `rolo migrate`.
+func LoadSessionsData(key ListKey) ([]SessionData, error) {
+	jsonPath, err := GetListPath(key)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Try to load JSON format first
-	if _, err := os.Stat(jsonPath); err == nil {
-		data, err := os.ReadFile(jsonPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read JSON config file: %w", err)
-		}
-		
-		var sessions []SessionData
-		if err := json.Unmarshal(data, &sessions); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON config file: %w", err)
-		}
-		
-		return sessions, nil
-	}
-	
-	// Fall back to old txt format
-	sessions, err := LoadSessions()
+	source, text, err := locateList(jsonPath)
 	if err != nil {
 		return nil, err
 	}
+	if source == "" {
+		return []SessionData{}, nil
+	}
 	
-	// Convert to SessionData format
-	sessionData := make([]SessionData, len(sessions))
-	for i, name := range sessions {
-		sessionData[i] = SessionData{Name: name, Deleted: false}
+	var doc *Document
+	if text {
+		names, err := LoadSessions()
+		if err != nil {
+			return nil, err
+		}
+		doc = &Document{Sessions: make([]SessionData, len(names))}
+		for i, name := range names {
+			doc.Sessions[i] = SessionData{Name: name}
+		}
+	} else {
+		store, err := StoreFor(source)
+		if err != nil {
+			return nil, err
+		}
+		err = readWithBackup(source, func(data []byte) error {
+			doc, _, err = decodeDocument(store, data)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JSON config file: %w", err)
+		}
 	}
 	
-	return sessionData, nil
+	// Duplicates are left for `rolo doctor --fix`, only the first is used
+	warnIssues(source, CheckSessions(doc.Sessions))
+	
+	return doc.Sessions, nil
 }
 
 // LoadSessions reads the session list from the config file
@@ -135,23 +292,47 @@ func LoadSessions() ([]string, error) {
 	return sessions, nil
 }
 
-// SaveSessionsData writes the session list with deleted state to the JSON config file
-func SaveSessionsData(sessions []SessionData) error {
-	if err := EnsureConfigDir(); err != nil {
+// SaveSessionsData writes a session list with the status of each entry,
+// recording the save and its cause in the list's undo history
+func SaveSessionsData(key ListKey, sessions []SessionData, cause Cause) error {
+	jsonPath, err := GetListPath(key)
+	if err != nil {
 		return err
 	}
 	
-	jsonPath, err := GetConfigJSONPath()
+	// The history is written next to the list, before the first save of a
+	// new list has created its directory
+	if err := os.MkdirAll(filepath.Dir(jsonPath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	
+	// Upgrade an old file first so its original is kept as a backup, the
+	// list is still saved if that fails
+	if _, _, err := migrateFile(jsonPath, true); err != nil {
+		warn("failed to migrate %s: %v", jsonPath, err)
+	}
+	
+	// History is best effort, it must not stand in the way of saving
+	if err := recordSave(jsonPath, cause, sessions); err != nil {
+		warn("failed to record history for %s: %v", jsonPath, err)
+	}
+	
+	return writeList(key, jsonPath, sessions)
+}
+
+// writeList writes a session list to jsonPath in the format of its
+// extension, its directory must exist
+func writeList(key ListKey, jsonPath string, sessions []SessionData) error {
+	store, err := StoreFor(jsonPath)
 	if err != nil {
 		return err
 	}
-	
-	data, err := json.MarshalIndent(sessions, "", "  ")
+	data, err := encodeDocument(store, Metadata{Server: key.server(), List: key.list()}, sessions)
 	if err != nil {
 		return fmt.Errorf("failed to marshal sessions: %w", err)
 	}
 	
-	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+	if err := writeFileAtomic(jsonPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write JSON config file: %w", err)
 	}
 	
@@ -174,13 +355,17 @@ func SaveSessions(sessions []string) error {
 		content += "\n" // Add trailing newline
 	}
 	
-	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+	if err := writeFileAtomic(configPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	
 	return nil
 }
 
+// configWarned records the unknown config keys already warned about, the
+// config is loaded more than once by some commands
+var configWarned sync.Map
+
 // LoadConfig reads the configuration settings from the config file
 // Returns default config if the file doesn't exist
 func LoadConfig() (*Config, error) {
@@ -191,25 +376,60 @@ func LoadConfig() (*Config, error) {
 	
 	// If file doesn't exist, return default config
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return &Config{
-			WrapAround: false,
-		}, nil
+		return DefaultConfig(), nil
 	}
 	
-	data, err := os.ReadFile(configPath)
+	store, err := StoreFor(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
 	
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	side, err := configSideFile(configPath, "")
+	if err != nil {
+		return nil, err
+	}
+	
+	// Only a file that can't be parsed is corrupt and restored from the
+	// backup, a value of the wrong type is the user's to fix
+	var data []byte
+	err = readWithBackupAt(configPath, side, func(contents []byte) error {
+		var doc any
+		if err := store.Unmarshal(contents, &doc); err != nil {
+			return syntaxError(configPath, contents, err)
+		}
+		data = contents
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+	
+	// Values of the wrong type are errors, unknown keys only warnings
+	problems, err := ValidateConfig(configPath, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+	for _, problem := range problems {
+		if !problem.Unknown {
+			return nil, fmt.Errorf("failed to load config file: %w", problem)
+		}
+	}
+	for _, problem := range problems {
+		if _, warned := configWarned.LoadOrStore(problem.Error(), true); !warned {
+			warn("%v", problem)
+		}
 	}
 	
-	return &config, nil
+	// Start from the defaults so keys missing from the file keep them
+	config := DefaultConfig()
+	if err := store.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+	return config, nil
 }
 
-// SaveConfig writes the configuration settings to the config file
+// SaveConfig writes the configuration settings to the config file, see
+// LockConfig
 func SaveConfig(config *Config) error {
 	if err := EnsureConfigDir(); err != nil {
 		return err
@@ -220,12 +440,26 @@ func SaveConfig(config *Config) error {
 		return err
 	}
 	
-	data, err := json.MarshalIndent(config, "", "  ")
+	store, err := StoreFor(configPath)
+	if err != nil {
+		return err
+	}
+	data, err := store.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// Write through symlinks, e.g. to a config kept in a dotfiles repo
+	target := configPath
+	if resolved, err := filepath.EvalSymlinks(configPath); err == nil {
+		target = resolved
+	}
+	side, err := configSideFile(configPath, "")
+	if err != nil {
+		return err
+	}
+	
+	if err := writeFileAtomicAt(target, side, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	
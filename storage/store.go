@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Store encodes and decodes rolo's files in one file format
+type Store interface {
+	// Name is the format's name, e.g. "json"
+	Name() string
+	// Marshal encodes v for writing to disk
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v, leaving fields missing from data as
+	// they were
+	Unmarshal(data []byte, v any) error
+}
+
+// stores maps file extensions to formats, in order of preference when
+// files in several formats exist
+var stores = []struct {
+	ext   string
+	store Store
+}{
+	{".json", jsonStore{}},
+	{".toml", tomlStore{}},
+	{".yaml", yamlStore{}},
+	{".yml", yamlStore{}},
+}
+
+// StoreFor returns the format of a file, chosen by its extension
+func StoreFor(path string) (Store, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, s := range stores {
+		if s.ext == ext {
+			return s.store, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported file format '%s' for %s", ext, path)
+}
+
+// supportedExt reports whether a file name has the extension of a format
+func supportedExt(name string) bool {
+	_, err := StoreFor(name)
+	return err == nil
+}
+
+// ambiguousWarned records the files already warned about by findFormat
+var ambiguousWarned sync.Map
+
+// findFormat returns the file for base, a path without extension, in
+// whichever format exists. New files are created as JSON.
+func findFormat(base string) string {
+	var found []string
+	for _, s := range stores {
+		if _, err := os.Stat(base + s.ext); err == nil {
+			found = append(found, base+s.ext)
+		}
+	}
+
+	if len(found) == 0 {
+		return base + ".json"
+	}
+	if len(found) > 1 {
+		if _, warned := ambiguousWarned.LoadOrStore(base, true); !warned {
+			warn("%s exists in several formats, using %s", filepath.Base(base), found[0])
+		}
+	}
+	return found[0]
+}
+
+type jsonStore struct{}
+
+func (jsonStore) Name() string { return "json" }
+
+func (jsonStore) Marshal(v any) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (jsonStore) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type tomlStore struct{}
+
+func (tomlStore) Name() string { return "toml" }
+
+func (tomlStore) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tomlStore) Unmarshal(data []byte, v any) error {
+	_, err := toml.Decode(string(data), v)
+	return err
+}
+
+type yamlStore struct{}
+
+func (yamlStore) Name() string { return "yaml" }
+
+func (yamlStore) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (yamlStore) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// toJSON converts a document in any format to JSON, which the migration
+// chain works on. Only JSON lists ever lacked a version, so documents in
+// other formats without one are taken to be current, as when hand written.
+func toJSON(store Store, data []byte) ([]byte, error) {
+	if store.Name() == "json" {
+		return data, nil
+	}
+
+	var doc map[string]any
+	if err := store.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("empty file")
+	}
+	if _, ok := doc["version"]; !ok {
+		doc["version"] = CurrentVersion
+	}
+	return json.Marshal(doc)
+}
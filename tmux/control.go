@@ -0,0 +1,229 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind is the type of a control-mode notification
+type EventKind int
+
+const (
+	// SessionsChanged is sent when a session is created or destroyed
+	SessionsChanged EventKind = iota
+	// SessionRenamed is sent when a session is renamed, Name holds the new name
+	SessionRenamed
+	// SessionWindowChanged is sent when a session's current window changes
+	SessionWindowChanged
+	// WatcherAttached is sent when the watcher's own control client is
+	// attached to a session. Control clients count towards the session's
+	// attached clients, so consumers may want to discount it.
+	WatcherAttached
+)
+
+// Event is a notification received from a tmux server
+type Event struct {
+	Kind      EventKind
+	SessionID string
+	Name      string
+}
+
+// Watcher delivers events from a tmux server until it is closed
+type Watcher interface {
+	// Events returns the channel events are delivered on
+	// It is closed once the watcher stops
+	Events() <-chan Event
+	// Close stops the watcher and releases its connection
+	Close() error
+}
+
+// controlFlags keep the control client from receiving pane output, sending
+// commands or resizing the windows of the session it attaches to
+const controlFlags = "no-output,read-only,ignore-size"
+
+// errWatcherClosed is returned by start once the watcher is closed
+var errWatcherClosed = errors.New("watcher closed")
+
+// reconnectDelay is how long to wait before re-attaching after the control
+// client was detached, e.g. because the session it was attached to closed
+const reconnectDelay = 500 * time.Millisecond
+
+// Watch opens a tmux control-mode (-C) connection and translates its
+// notifications into events. The connection is re-established whenever
+// tmux detaches it, so the watcher keeps running while sessions come and go.
+func (c *ExecClient) Watch() (Watcher, error) {
+	w := &controlWatcher{
+		client: c,
+		events: make(chan Event, 16),
+		done:   make(chan struct{}),
+	}
+
+	// Fail fast if control mode can't be started at all
+	cmd, stdout, err := w.start()
+	if err != nil {
+		return nil, err
+	}
+
+	go w.run(cmd, stdout)
+	return w, nil
+}
+
+type controlWatcher struct {
+	client *ExecClient
+	events chan Event
+	done   chan struct{}
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	closed bool
+}
+
+func (w *controlWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *controlWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	if w.stdin != nil {
+		w.stdin.Close()
+	}
+	if w.cmd != nil && w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// start launches a control-mode client attached to the most recent session.
+// A client started after Close, while run was reconnecting, is killed again
+// so it doesn't stay attached to the server.
+func (w *controlWatcher) start() (*exec.Cmd, io.ReadCloser, error) {
+	cmd := w.client.query("-C", "attach-session", "-f", controlFlags)
+	// Keep stdin open so tmux doesn't treat EOF as a request to detach
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, nil, errWatcherClosed
+	}
+	w.cmd, w.stdin = cmd, stdin
+
+	return cmd, stdout, nil
+}
+
+func (w *controlWatcher) run(cmd *exec.Cmd, stdout io.ReadCloser) {
+	defer close(w.events)
+
+	for {
+		w.read(stdout)
+		cmd.Wait()
+
+		// Reconnect until closed, tmux detaches us when our session is destroyed
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-time.After(reconnectDelay):
+			}
+
+			var err error
+			cmd, stdout, err = w.start()
+			if err == nil {
+				break
+			}
+			if errors.Is(err, errWatcherClosed) {
+				return
+			}
+		}
+
+		// Sessions may have changed while we were disconnected
+		if !w.send(Event{Kind: SessionsChanged}) {
+			return
+		}
+	}
+}
+
+// read forwards notifications until the control client exits
+func (w *controlWatcher) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	inBlock := false
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Skip command output wrapped in %begin/%end guards
+		switch {
+		case strings.HasPrefix(line, "%begin "):
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "%end "), strings.HasPrefix(line, "%error "):
+			inBlock = false
+			continue
+		case inBlock:
+			continue
+		}
+
+		if strings.HasPrefix(line, "%exit") {
+			return
+		}
+		if event, ok := parseNotification(line); ok {
+			if !w.send(event) {
+				return
+			}
+		}
+	}
+}
+
+// send delivers an event, returning false if the watcher was closed
+func (w *controlWatcher) send(event Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// parseNotification converts a control-mode notification line into an event
+func parseNotification(line string) (Event, bool) {
+	name, args, _ := strings.Cut(line, " ")
+	switch name {
+	case "%sessions-changed":
+		return Event{Kind: SessionsChanged}, true
+	case "%session-renamed":
+		id, newName, _ := strings.Cut(args, " ")
+		return Event{Kind: SessionRenamed, SessionID: id, Name: newName}, true
+	case "%session-window-changed":
+		id, _, _ := strings.Cut(args, " ")
+		return Event{Kind: SessionWindowChanged, SessionID: id}, true
+	case "%session-changed":
+		id, newName, _ := strings.Cut(args, " ")
+		return Event{Kind: WatcherAttached, SessionID: id, Name: newName}, true
+	}
+	return Event{}, false
+}
@@ -0,0 +1,547 @@
+package tmux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation names accepted by FakeClient.FailOn
+const (
+	OpListSessions   = "ListSessions"
+	OpCurrentSession = "CurrentSession"
+	OpCurrentClient  = "CurrentClient"
+	OpSwitchTo       = "SwitchTo"
+	OpHasSession     = "HasSession"
+	OpWatch          = "Watch"
+	OpAttach         = "Attach"
+	OpNewSession     = "NewSession"
+	OpListClients    = "ListClients"
+	OpSwitchClient   = "SwitchClient"
+	OpKillSession    = "KillSession"
+	OpListPanes      = "ListPanes"
+)
+
+// fakeShell is the command FakeClient panes run unless set otherwise
+const fakeShell = "bash"
+
+// FakeClient is an in-memory Client that simulates a tmux server
+// It is safe for concurrent use
+type FakeClient struct {
+	// Clock supplies creation and activity times, defaults to time.Now
+	Clock func() time.Time
+
+	mu       sync.Mutex
+	sessions []Session
+	nextID   int
+	clients  map[string]string
+	panes    map[string][]string
+	current  string
+	failures map[string]error
+	switches []string
+	attaches []string
+	watchers []*fakeWatcher
+}
+
+// NewFakeClient returns a FakeClient with the given sessions and a single
+// client named "client-0" attached to the first session, if any
+func NewFakeClient(sessions ...string) *FakeClient {
+	f := &FakeClient{
+		clients:  make(map[string]string),
+		panes:    make(map[string][]string),
+		failures: make(map[string]error),
+	}
+	for _, name := range sessions {
+		f.addSession(name)
+	}
+	if len(f.sessions) > 0 {
+		f.clients["client-0"] = f.sessions[0].ID
+		f.current = "client-0"
+	}
+	return f
+}
+
+// AddSession creates a new session and returns it
+// If the session already exists the existing one is returned
+func (f *FakeClient) AddSession(name string) Session {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	session, created := f.addSession(name)
+	if created {
+		f.emit(Event{Kind: SessionsChanged})
+	}
+	return session
+}
+
+// KillSession removes a session and detaches any clients attached to it
+func (f *FakeClient) KillSession(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpKillSession]; err != nil {
+		return err
+	}
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("failed to kill session '%s': can't find session", target)
+	}
+	id := f.sessions[i].ID
+	f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+	delete(f.panes, id)
+
+	for client, session := range f.clients {
+		if session == id {
+			delete(f.clients, client)
+		}
+	}
+	f.emit(Event{Kind: SessionsChanged})
+
+	return nil
+}
+
+// ListClients returns every simulated client sorted by name
+func (f *FakeClient) ListClients() ([]AttachedClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpListClients]; err != nil {
+		return nil, err
+	}
+
+	clients := make([]AttachedClient, 0, len(f.clients))
+	for name, id := range f.clients {
+		session := f.sessions[f.resolve(id)]
+		clients = append(clients, AttachedClient{Name: name, SessionID: id, SessionName: session.Name})
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+
+	return clients, nil
+}
+
+// ListPanes returns the panes of every simulated session in creation
+// order. Sessions without commands set by SetPaneCommands have one pane per
+// window running fakeShell.
+func (f *FakeClient) ListPanes() ([]Pane, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpListPanes]; err != nil {
+		return nil, err
+	}
+
+	var panes []Pane
+	for _, session := range f.sessions {
+		commands, ok := f.panes[session.ID]
+		if !ok {
+			commands = make([]string, session.Windows)
+			for i := range commands {
+				commands[i] = fakeShell
+			}
+		}
+		for _, command := range commands {
+			panes = append(panes, Pane{SessionID: session.ID, Command: command})
+		}
+	}
+
+	return panes, nil
+}
+
+// SetPaneCommands sets the commands running in the panes of the target
+// session, one pane per command
+func (f *FakeClient) SetPaneCommands(target string, commands ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("can't find session: %s", target)
+	}
+	f.panes[f.sessions[i].ID] = append([]string{}, commands...)
+
+	return nil
+}
+
+// SwitchClient moves the named client to the target session
+func (f *FakeClient) SwitchClient(client, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpSwitchClient]; err != nil {
+		return err
+	}
+	if _, ok := f.clients[client]; !ok {
+		return fmt.Errorf("failed to switch client '%s': can't find client", client)
+	}
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("failed to switch client '%s' to session '%s': can't find session", client, target)
+	}
+	f.clients[client] = f.sessions[i].ID
+
+	return nil
+}
+
+// UpdateSession applies update to the target session, e.g. to set its
+// window count, path or activity time. The id and name cannot be changed.
+func (f *FakeClient) UpdateSession(target string, update func(*Session)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("can't find session: %s", target)
+	}
+	session := f.sessions[i]
+	update(&session)
+	session.ID, session.Name = f.sessions[i].ID, f.sessions[i].Name
+	f.sessions[i] = session
+	f.emit(Event{Kind: SessionWindowChanged, SessionID: session.ID})
+
+	return nil
+}
+
+// RenameSession renames a session, keeping its id and attached clients
+func (f *FakeClient) RenameSession(target, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("can't find session: %s", target)
+	}
+	if f.resolve("="+newName) != -1 {
+		return fmt.Errorf("duplicate session: %s", newName)
+	}
+	f.sessions[i].Name = newName
+	f.emit(Event{Kind: SessionRenamed, SessionID: f.sessions[i].ID, Name: newName})
+
+	return nil
+}
+
+// AttachClient attaches a named client to a session
+func (f *FakeClient) AttachClient(client, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("can't find session: %s", target)
+	}
+	f.clients[client] = f.sessions[i].ID
+
+	return nil
+}
+
+// SetCurrentClient selects the client that CurrentSession and SwitchTo act on
+// An empty name simulates running outside of tmux
+func (f *FakeClient) SetCurrentClient(client string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.current = client
+}
+
+// FailOn makes every subsequent call of the given operation return err
+// Passing a nil err clears the failure
+func (f *FakeClient) FailOn(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.failures, op)
+		return
+	}
+	f.failures[op] = err
+}
+
+// Sessions returns a copy of the current sessions in creation order
+func (f *FakeClient) Sessions() []Session {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.snapshot()
+}
+
+// Switches returns the targets of every successful SwitchTo call in order
+func (f *FakeClient) Switches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.switches...)
+}
+
+// Attaches returns the targets of every successful Attach call in order
+func (f *FakeClient) Attaches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.attaches...)
+}
+
+// Inside reports whether a current client is set, see SetCurrentClient
+func (f *FakeClient) Inside() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.current != ""
+}
+
+// Attach simulates attaching a new client to the target session
+// The new client becomes the current client, as if rolo were now inside it
+func (f *FakeClient) Attach(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpAttach]; err != nil {
+		return err
+	}
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("failed to attach to session '%s': can't find session", target)
+	}
+
+	client := fmt.Sprintf("client-%d", len(f.attaches)+1)
+	f.clients[client] = f.sessions[i].ID
+	f.current = client
+	f.attaches = append(f.attaches, target)
+
+	return nil
+}
+
+// NewSession creates a session, failing if the name is taken
+func (f *FakeClient) NewSession(name, dir string) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpNewSession]; err != nil {
+		return Session{}, err
+	}
+	session, created := f.addSession(name)
+	if !created {
+		return Session{}, fmt.Errorf("failed to create session '%s': duplicate session", name)
+	}
+	if dir != "" {
+		f.sessions[len(f.sessions)-1].Path = dir
+		session.Path = dir
+	}
+	f.emit(Event{Kind: SessionsChanged})
+
+	return session, nil
+}
+
+// ListSessions returns all simulated sessions
+func (f *FakeClient) ListSessions() ([]Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpListSessions]; err != nil {
+		return nil, err
+	}
+
+	return f.snapshot(), nil
+}
+
+// CurrentClient returns the name of the current client
+func (f *FakeClient) CurrentClient() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpCurrentClient]; err != nil {
+		return "", err
+	}
+	if f.current == "" {
+		return "", fmt.Errorf("not in a tmux client: no current client")
+	}
+	return f.current, nil
+}
+
+// CurrentSession returns the session the current client is attached to
+func (f *FakeClient) CurrentSession() (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpCurrentSession]; err != nil {
+		return Session{}, err
+	}
+
+	id, ok := f.clients[f.current]
+	if f.current == "" || !ok {
+		return Session{}, fmt.Errorf("not in a tmux session: no current client")
+	}
+
+	return f.snapshot()[f.resolve(id)], nil
+}
+
+// SwitchTo moves the current client to the target session
+func (f *FakeClient) SwitchTo(target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpSwitchTo]; err != nil {
+		return err
+	}
+	i := f.resolve(target)
+	if i == -1 {
+		return fmt.Errorf("failed to switch to session '%s': can't find session", target)
+	}
+	if f.current == "" {
+		return fmt.Errorf("failed to switch to session '%s': no current client", target)
+	}
+
+	f.clients[f.current] = f.sessions[i].ID
+	f.sessions[i].Activity = f.now()
+	f.switches = append(f.switches, target)
+
+	return nil
+}
+
+// HasSession reports whether the target session exists
+func (f *FakeClient) HasSession(target string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpHasSession]; err != nil {
+		return false, err
+	}
+
+	return f.resolve(target) != -1, nil
+}
+
+// Watch returns a watcher that receives an event for every simulated change
+func (f *FakeClient) Watch() (Watcher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failures[OpWatch]; err != nil {
+		return nil, err
+	}
+
+	w := &fakeWatcher{owner: f, events: make(chan Event, 64)}
+	f.watchers = append(f.watchers, w)
+	return w, nil
+}
+
+// Emit delivers an arbitrary event to every open watcher
+func (f *FakeClient) Emit(event Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.emit(event)
+}
+
+// emit delivers an event to every open watcher, callers must hold f.mu
+// Events are dropped for watchers whose buffer is full
+func (f *FakeClient) emit(event Event) {
+	for _, w := range f.watchers {
+		select {
+		case w.events <- event:
+		default:
+		}
+	}
+}
+
+// addSession creates a session if needed, callers must hold f.mu
+// Reports whether a new session was created
+func (f *FakeClient) addSession(name string) (Session, bool) {
+	if i := f.resolve("=" + name); i != -1 {
+		return f.sessions[i], false
+	}
+	now := f.now()
+	session := Session{
+		ID:       fmt.Sprintf("$%d", f.nextID),
+		Name:     name,
+		Windows:  1,
+		Activity: now,
+		Created:  now,
+	}
+	f.nextID++
+	f.sessions = append(f.sessions, session)
+	return session, true
+}
+
+// snapshot copies the sessions with their attached client counts filled in
+// Callers must hold f.mu
+func (f *FakeClient) snapshot() []Session {
+	sessions := append([]Session{}, f.sessions...)
+	for i := range sessions {
+		sessions[i].Attached = 0
+		for _, id := range f.clients {
+			if id == sessions[i].ID {
+				sessions[i].Attached++
+			}
+		}
+	}
+	return sessions
+}
+
+func (f *FakeClient) now() time.Time {
+	if f.Clock != nil {
+		return f.Clock()
+	}
+	return time.Now()
+}
+
+// resolve finds a session the way tmux resolves -t targets: by "$id", by
+// exact "=name", by name, and finally by unique name prefix
+// Callers must hold f.mu
+func (f *FakeClient) resolve(target string) int {
+	if strings.HasPrefix(target, "$") {
+		for i, session := range f.sessions {
+			if session.ID == target {
+				return i
+			}
+		}
+		return -1
+	}
+
+	exact := strings.HasPrefix(target, "=")
+	name := strings.TrimPrefix(target, "=")
+	for i, session := range f.sessions {
+		if session.Name == name {
+			return i
+		}
+	}
+	if exact {
+		return -1
+	}
+
+	match := -1
+	for i, session := range f.sessions {
+		if strings.HasPrefix(session.Name, name) {
+			if match != -1 {
+				return -1
+			}
+			match = i
+		}
+	}
+	return match
+}
+
+type fakeWatcher struct {
+	owner  *FakeClient
+	events chan Event
+}
+
+func (w *fakeWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *fakeWatcher) Close() error {
+	w.owner.mu.Lock()
+	defer w.owner.mu.Unlock()
+
+	for i, other := range w.owner.watchers {
+		if other == w {
+			w.owner.watchers = append(w.owner.watchers[:i], w.owner.watchers[i+1:]...)
+			close(w.events)
+			break
+		}
+	}
+	return nil
+}
+
+var _ Client = (*FakeClient)(nil)
+var _ Client = (*ExecClient)(nil)
@@ -0,0 +1,148 @@
+package tmux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeResolve(t *testing.T) {
+	f := NewFakeClient("work", "work-notes", "play", "scratch")
+
+	tests := []struct {
+		target string
+		want   string
+		found  bool
+	}{
+		{target: "$0", want: "work", found: true},
+		{target: "$2", want: "play", found: true},
+		{target: "$9", found: false},
+		{target: "=work", want: "work", found: true},
+		{target: "=wo", found: false},
+		{target: "=play", want: "play", found: true},
+		{target: "work", want: "work", found: true},
+		{target: "work-", want: "work-notes", found: true},
+		{target: "pl", want: "play", found: true},
+		{target: "scr", want: "scratch", found: true},
+		{target: "wo", found: false},
+		{target: "missing", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			ok, err := f.HasSession(tt.target)
+			if err != nil {
+				t.Fatalf("HasSession(%q): %v", tt.target, err)
+			}
+			if ok != tt.found {
+				t.Fatalf("HasSession(%q) = %v, want %v", tt.target, ok, tt.found)
+			}
+			if !tt.found {
+				if err := f.SwitchTo(tt.target); err == nil {
+					t.Fatalf("SwitchTo(%q) succeeded for a missing session", tt.target)
+				}
+				return
+			}
+
+			if err := f.SwitchTo(tt.target); err != nil {
+				t.Fatalf("SwitchTo(%q): %v", tt.target, err)
+			}
+			current, err := f.CurrentSession()
+			if err != nil {
+				t.Fatalf("CurrentSession: %v", err)
+			}
+			if current.Name != tt.want {
+				t.Errorf("SwitchTo(%q) landed on %q, want %q", tt.target, current.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeKillSessionDetachesClients(t *testing.T) {
+	f := NewFakeClient("work", "play")
+	if err := f.AttachClient("client-1", "=work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AttachClient("client-2", "=play"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.KillSession("=work"); err != nil {
+		t.Fatalf("KillSession: %v", err)
+	}
+
+	clients, err := f.ListClients()
+	if err != nil {
+		t.Fatalf("ListClients: %v", err)
+	}
+	if len(clients) != 1 || clients[0].Name != "client-2" || clients[0].SessionName != "play" {
+		t.Errorf("clients after kill = %+v, want only client-2 on play", clients)
+	}
+	if _, err := f.CurrentSession(); err == nil {
+		t.Error("CurrentSession succeeded for the detached current client")
+	}
+	if ok, _ := f.HasSession("$0"); ok {
+		t.Error("killed session still resolves by id")
+	}
+	if err := f.KillSession("=work"); err == nil {
+		t.Error("killing a missing session succeeded")
+	}
+
+	sessions := f.Sessions()
+	if len(sessions) != 1 || sessions[0].Name != "play" || sessions[0].Attached != 1 {
+		t.Errorf("sessions after kill = %+v, want play with one client", sessions)
+	}
+}
+
+func TestFakeRenameSessionEmitsEvents(t *testing.T) {
+	f := NewFakeClient("work", "play")
+	watcher, err := f.Watch()
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	if err := f.RenameSession("=work", "job"); err != nil {
+		t.Fatalf("RenameSession: %v", err)
+	}
+	if err := f.RenameSession("=job", "play"); err == nil {
+		t.Error("renaming onto an existing name succeeded")
+	}
+	if err := f.RenameSession("=missing", "other"); err == nil {
+		t.Error("renaming a missing session succeeded")
+	}
+	f.AddSession("scratch")
+	if err := f.KillSession("=scratch"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Event{
+		{Kind: SessionRenamed, SessionID: "$0", Name: "job"},
+		{Kind: SessionsChanged},
+		{Kind: SessionsChanged},
+	}
+	for i, expected := range want {
+		select {
+		case event := <-watcher.Events():
+			if event != expected {
+				t.Errorf("event %d = %+v, want %+v", i, event, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", i)
+		}
+	}
+
+	sessions := f.Sessions()
+	if sessions[0].ID != "$0" || sessions[0].Name != "job" {
+		t.Errorf("renamed session = %+v, want $0 named job", sessions[0])
+	}
+	if current, _ := f.CurrentSession(); current.Name != "job" {
+		t.Errorf("current client is on %q after rename, want job", current.Name)
+	}
+
+	if err := watcher.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, ok := <-watcher.Events(); ok {
+		t.Error("events channel still open after Close")
+	}
+	f.AddSession("after-close")
+}
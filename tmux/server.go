@@ -0,0 +1,89 @@
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultServerKey identifies the default tmux server
+const DefaultServerKey = "default"
+
+// Server identifies which tmux server to talk to
+// The zero value is the default server
+type Server struct {
+	// SocketName is passed to tmux as -L
+	SocketName string
+	// SocketPath is passed to tmux as -S and takes precedence over SocketName
+	SocketPath string
+}
+
+// Args returns the tmux flags that select this server
+func (s Server) Args() []string {
+	if s.SocketPath != "" {
+		return []string{"-S", s.SocketPath}
+	}
+	if s.SocketName != "" {
+		return []string{"-L", s.SocketName}
+	}
+	return nil
+}
+
+// Key returns a stable identifier for the server
+// Sockets in tmux's own socket directory are keyed by their name so that
+// `-L work` and `-S /tmp/tmux-1000/work` resolve to the same key
+func (s Server) Key() string {
+	if s.SocketPath != "" {
+		path := filepath.Clean(s.SocketPath)
+		if filepath.Dir(path) == socketDir() {
+			return filepath.Base(path)
+		}
+		return path
+	}
+	if s.SocketName != "" {
+		return s.SocketName
+	}
+	return DefaultServerKey
+}
+
+// String returns a human readable description of the server
+func (s Server) String() string {
+	if s.SocketPath != "" {
+		return fmt.Sprintf("socket %s", s.SocketPath)
+	}
+	return fmt.Sprintf("socket name %s", s.Key())
+}
+
+// DetectServer returns the server rolo is running inside of, based on $TMUX
+// Returns the default server when not inside tmux
+func DetectServer() Server {
+	return parseTmuxEnv(os.Getenv("TMUX"))
+}
+
+// parseTmuxEnv extracts the socket path from a $TMUX value
+// The format is "<socket path>,<server pid>,<session index>"
+func parseTmuxEnv(value string) Server {
+	if value == "" {
+		return Server{}
+	}
+
+	path := value
+	if i := strings.Index(value, ","); i != -1 {
+		path = value[:i]
+	}
+	if path == "" {
+		return Server{}
+	}
+
+	return Server{SocketPath: path}
+}
+
+// socketDir returns the directory tmux creates its sockets in
+func socketDir() string {
+	tmpDir := os.Getenv("TMUX_TMPDIR")
+	if tmpDir == "" {
+		tmpDir = "/tmp"
+	}
+	return filepath.Join(filepath.Clean(tmpDir), fmt.Sprintf("tmux-%d", os.Getuid()))
+}
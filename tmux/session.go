@@ -0,0 +1,151 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session describes a tmux session
+type Session struct {
+	// ID is the server-assigned session id, e.g. "$3", which survives renames
+	ID   string
+	Name string
+	// Windows is the number of windows in the session
+	Windows int
+	// Attached is the number of clients attached to the session
+	Attached int
+	// Activity is the time of the last activity in the session
+	Activity time.Time
+	// Created is the time the session was created
+	Created time.Time
+	// Path is the session's working directory
+	Path string
+	// Grouped reports whether the session is part of a session group
+	Grouped bool
+	// Group is the name of the session group, if any
+	Group string
+}
+
+// Target returns the tmux target that unambiguously selects this session
+func (s Session) Target() string {
+	return Target(s.ID, s.Name)
+}
+
+// Idle returns how long the session has had no activity
+func (s Session) Idle(now time.Time) time.Duration {
+	if s.Activity.IsZero() {
+		return 0
+	}
+	return now.Sub(s.Activity)
+}
+
+// Target builds a tmux target for a session, preferring its id and falling
+// back to an exact name match so that names are never treated as prefixes.
+// tmux numbers ids from $0 again after a server restart, so a stored id must
+// have been checked against the running server first, see
+// storage.FollowRenames.
+func Target(id, name string) string {
+	if id != "" {
+		return id
+	}
+	return "=" + name
+}
+
+// FindSession looks up a session by id, falling back to its name
+func FindSession(sessions []Session, id, name string) (Session, bool) {
+	if id != "" {
+		for _, session := range sessions {
+			if session.ID == id {
+				return session, true
+			}
+		}
+	}
+	for _, session := range sessions {
+		if session.Name == name {
+			return session, true
+		}
+	}
+	return Session{}, false
+}
+
+// FormatAge renders a duration the way rolo displays idle and uptime values,
+// e.g. "now", "42s", "5m", "3h", "2d"
+func FormatAge(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// fieldSeparator separates the fields of sessionFormat
+// The ASCII unit separator can't be typed into session names or paths
+const fieldSeparator = "\x1f"
+
+// sessionFields are the format variables requested for every session, in
+// the order parseSession expects them
+var sessionFields = []string{
+	"#{session_id}",
+	"#{session_name}",
+	"#{session_windows}",
+	"#{session_attached}",
+	"#{session_activity}",
+	"#{session_created}",
+	"#{session_path}",
+	"#{session_grouped}",
+	"#{session_group}",
+}
+
+// sessionFormat is the list-sessions/display-message format parsed by parseSession
+var sessionFormat = strings.Join(sessionFields, fieldSeparator)
+
+// parseSession parses a line produced by sessionFormat
+// Numeric fields that fail to parse are left at their zero value
+func parseSession(line string) Session {
+	fields := strings.Split(line, fieldSeparator)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "$") {
+		return Session{Name: line}
+	}
+
+	// Pad missing trailing fields so older tmux versions still parse
+	for len(fields) < len(sessionFields) {
+		fields = append(fields, "")
+	}
+
+	return Session{
+		ID:       fields[0],
+		Name:     fields[1],
+		Windows:  atoi(fields[2]),
+		Attached: atoi(fields[3]),
+		Activity: unixTime(fields[4]),
+		Created:  unixTime(fields[5]),
+		Path:     fields[6],
+		Grouped:  fields[7] == "1",
+		Group:    fields[8],
+	}
+}
+
+func atoi(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func unixTime(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
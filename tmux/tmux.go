@@ -2,14 +2,98 @@ package tmux
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 )
 
-// GetActiveSessions returns a list of active tmux session names
-func GetActiveSessions() ([]string, error) {
+// Client is the set of tmux operations rolo depends on
+type Client interface {
+	// ListSessions returns all active sessions
+	ListSessions() ([]Session, error)
+	// CurrentSession returns the session the calling client is attached to
+	CurrentSession() (Session, error)
+	// CurrentClient returns the name of the calling client, as used by -c
+	CurrentClient() (string, error)
+	// SwitchTo switches the calling client to the target session
+	SwitchTo(target string) error
+	// HasSession reports whether the target session exists
+	HasSession(target string) (bool, error)
+	// Watch subscribes to session notifications from the server
+	Watch() (Watcher, error)
+	// Inside reports whether rolo is running inside a client of this server
+	Inside() bool
+	// Attach attaches the terminal to the target session, blocking until
+	// the client detaches. Used instead of SwitchTo when not inside tmux.
+	Attach(target string) error
+	// NewSession creates a detached session in dir, or the current
+	// directory if dir is "", starting the server if needed
+	NewSession(name, dir string) (Session, error)
+	// ListClients returns the clients attached to the server
+	ListClients() ([]AttachedClient, error)
+	// SwitchClient switches the named client to the target session
+	SwitchClient(client, target string) error
+	// KillSession destroys the target session
+	KillSession(target string) error
+	// ListPanes returns the panes of every session
+	ListPanes() ([]Pane, error)
+}
+
+// AttachedClient is a client attached to a tmux session
+type AttachedClient struct {
+	// Name is the client name, usually its tty, as used by -c
+	Name        string
+	SessionID   string
+	SessionName string
+}
+
+// clientFormat is the list-clients format parsed by parseClient
+var clientFormat = strings.Join([]string{"#{client_name}", "#{session_id}", "#{session_name}"}, fieldSeparator)
+
+// Pane is a pane in one of a session's windows
+type Pane struct {
+	SessionID string
+	// Command is the command running in the pane, e.g. "vim" or "zsh"
+	Command string
+}
+
+// paneFormat is the list-panes format parsed by ListPanes
+var paneFormat = strings.Join([]string{"#{session_id}", "#{pane_current_command}"}, fieldSeparator)
+
+// ExecClient is a Client that shells out to the tmux binary
+type ExecClient struct {
+	// Binary is the tmux executable to run, defaults to "tmux"
+	Binary string
+	// Server selects the tmux server every command is sent to
+	Server Server
+}
+
+// NewExecClient returns a Client backed by the tmux binary on $PATH
+// talking to the given server
+func NewExecClient(server Server) *ExecClient {
+	return &ExecClient{Binary: "tmux", Server: server}
+}
+
+func (c *ExecClient) command(args ...string) *exec.Cmd {
+	binary := c.Binary
+	if binary == "" {
+		binary = "tmux"
+	}
+	return exec.Command(binary, append(c.Server.Args(), args...)...)
+}
+
+// query builds a command whose output rolo parses. tmux replaces control
+// characters such as fieldSeparator with "_" for clients it doesn't believe
+// are UTF-8 capable, which is any client started outside tmux without a
+// UTF-8 locale, so -u is always passed.
+func (c *ExecClient) query(args ...string) *exec.Cmd {
+	return c.command(append([]string{"-u"}, args...)...)
+}
+
+// ListSessions returns a list of active tmux sessions
+func (c *ExecClient) ListSessions() ([]Session, error) {
 	// Run tmux list-sessions command
-	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
+	cmd := c.query("list-sessions", "-F", sessionFormat)
 	output, err := cmd.Output()
 	if err != nil {
 		// Check if it's because tmux isn't running
@@ -19,55 +103,207 @@ func GetActiveSessions() ([]string, error) {
 		return nil, fmt.Errorf("failed to run tmux: %w", err)
 	}
 
-	// Parse the output
-	content := strings.TrimSpace(string(output))
-	if content == "" {
-		return []string{}, nil
+	lines := parseLines(string(output))
+	sessions := make([]Session, 0, len(lines))
+	for _, line := range lines {
+		sessions = append(sessions, parseSession(line))
 	}
 
-	sessions := strings.Split(content, "\n")
-	
-	// Filter out empty lines
-	filtered := make([]string, 0, len(sessions))
-	for _, session := range sessions {
-		session = strings.TrimSpace(session)
-		if session != "" {
-			filtered = append(filtered, session)
+	return sessions, nil
+}
+
+// CurrentSession returns the current tmux session
+// Returns an error if not inside a tmux session
+func (c *ExecClient) CurrentSession() (Session, error) {
+	cmd := c.query("display-message", "-p", sessionFormat)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return Session{}, fmt.Errorf("not in a tmux session: %s", string(exitErr.Stderr))
 		}
+		return Session{}, fmt.Errorf("failed to get current session: %w", err)
 	}
 
-	return filtered, nil
+	line := strings.TrimSpace(string(output))
+	if line == "" {
+		return Session{}, fmt.Errorf("no current session found")
+	}
+
+	return parseSession(line), nil
 }
 
-// GetCurrentSession returns the name of the current tmux session
-// Returns an error if not inside a tmux session
-func GetCurrentSession() (string, error) {
-	cmd := exec.Command("tmux", "display-message", "-p", "#S")
+// CurrentClient returns the name of the client rolo runs in, usually its tty
+func (c *ExecClient) CurrentClient() (string, error) {
+	cmd := c.query("display-message", "-p", "#{client_name}")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("not in a tmux client: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to get current client: %w", err)
+	}
+
+	name := strings.TrimSpace(string(output))
+	if name == "" {
+		return "", fmt.Errorf("no current client found")
+	}
+	return name, nil
+}
+
+// SwitchTo switches to the target tmux session
+func (c *ExecClient) SwitchTo(target string) error {
+	cmd := c.command("switch-client", "-t", target)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("failed to switch to session '%s': %s", target, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("failed to switch to session '%s': %w", target, err)
+	}
+
+	return nil
+}
+
+// Inside reports whether $TMUX points at the server this client talks to
+func (c *ExecClient) Inside() bool {
+	if os.Getenv("TMUX") == "" {
+		return false
+	}
+	return DetectServer().Key() == c.Server.Key()
+}
+
+// Attach runs attach-session in the foreground on the current terminal
+func (c *ExecClient) Attach(target string) error {
+	cmd := c.command("attach-session", "-t", target)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to attach to session '%s': %w", target, err)
+	}
+
+	return nil
+}
+
+// NewSession creates a detached session and returns it
+func (c *ExecClient) NewSession(name, dir string) (Session, error) {
+	args := []string{"new-session", "-d", "-s", name, "-P", "-F", sessionFormat}
+	if dir != "" {
+		args = append(args, "-c", dir)
+	}
+	cmd := c.query(args...)
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return "", fmt.Errorf("not in a tmux session: %s", string(exitErr.Stderr))
+			return Session{}, fmt.Errorf("failed to create session '%s': %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return Session{}, fmt.Errorf("failed to create session '%s': %w", name, err)
+	}
+
+	return parseSession(strings.TrimSpace(string(output))), nil
+}
+
+// ListClients returns all clients attached to the server
+func (c *ExecClient) ListClients() ([]AttachedClient, error) {
+	cmd := c.query("list-clients", "-F", clientFormat)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("tmux command failed: %s", string(exitErr.Stderr))
 		}
-		return "", fmt.Errorf("failed to get current session: %w", err)
+		return nil, fmt.Errorf("failed to run tmux: %w", err)
 	}
 
-	session := strings.TrimSpace(string(output))
-	if session == "" {
-		return "", fmt.Errorf("no current session found")
+	lines := parseLines(string(output))
+	clients := make([]AttachedClient, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.SplitN(line, fieldSeparator, 3)
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		clients = append(clients, AttachedClient{Name: fields[0], SessionID: fields[1], SessionName: fields[2]})
 	}
 
-	return session, nil
+	return clients, nil
 }
 
-// SwitchToSession switches to the specified tmux session
-func SwitchToSession(sessionName string) error {
-	cmd := exec.Command("tmux", "switch-client", "-t", sessionName)
+// SwitchClient switches the named client to the target session
+func (c *ExecClient) SwitchClient(client, target string) error {
+	cmd := c.command("switch-client", "-c", client, "-t", target)
 	if err := cmd.Run(); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("failed to switch to session '%s': %s", sessionName, string(exitErr.Stderr))
+			return fmt.Errorf("failed to switch client '%s' to session '%s': %s", client, target, strings.TrimSpace(string(exitErr.Stderr)))
 		}
-		return fmt.Errorf("failed to switch to session '%s': %w", sessionName, err)
+		return fmt.Errorf("failed to switch client '%s' to session '%s': %w", client, target, err)
 	}
 
 	return nil
 }
+
+// KillSession destroys the target session
+func (c *ExecClient) KillSession(target string) error {
+	cmd := c.command("kill-session", "-t", target)
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("failed to kill session '%s': %s", target, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("failed to kill session '%s': %w", target, err)
+	}
+
+	return nil
+}
+
+// ListPanes returns the panes of every session on the server
+func (c *ExecClient) ListPanes() ([]Pane, error) {
+	cmd := c.query("list-panes", "-a", "-F", paneFormat)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("tmux command failed: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("failed to run tmux: %w", err)
+	}
+
+	lines := parseLines(string(output))
+	panes := make([]Pane, 0, len(lines))
+	for _, line := range lines {
+		id, command, _ := strings.Cut(line, fieldSeparator)
+		panes = append(panes, Pane{SessionID: id, Command: command})
+	}
+
+	return panes, nil
+}
+
+// HasSession reports whether the target tmux session exists
+func (c *ExecClient) HasSession(target string) (bool, error) {
+	cmd := c.command("has-session", "-t", target)
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// has-session exits non-zero when the session is missing
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to run tmux: %w", err)
+	}
+
+	return true, nil
+}
+
+// parseLines splits tmux output into trimmed, non-empty lines
+func parseLines(output string) []string {
+	content := strings.TrimSpace(output)
+	if content == "" {
+		return []string{}
+	}
+
+	lines := strings.Split(content, "\n")
+
+	// Filter out empty lines
+	filtered := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			filtered = append(filtered, line)
+		}
+	}
+
+	return filtered
+}
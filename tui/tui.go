@@ -2,9 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"rolo/navigation"
 	"rolo/storage"
 	"rolo/tmux"
 )
@@ -51,76 +54,495 @@ type model struct {
 	cursor     int
 	mode       mode
 	onSave     func([]storage.SessionData) error
+	onKill     func(storage.SessionData) error
 	wrapAround bool
+
+	client  tmux.Client
+	watcher tmux.Watcher
+	live    []tmux.Session
+
+	// seen holds the ids of sessions that were live while the UI was open,
+	// so sessions that disappear can be told apart from stale entries
+	seen map[string]bool
+
+	// controlSession is the session rolo's own control client is attached to
+	controlSession string
+
+	// confirmKill is set while waiting for the user to confirm a kill
+	confirmKill bool
+
+	// editing is set while the user types a field=value metadata change
+	// for the session under the cursor into input
+	editing bool
+	input   string
+
+	// status is a one-line message shown below the list, e.g. errors
+	status string
+
+	// selected is the session to switch to after the UI exits
+	selected *storage.SessionData
+
+	// lists switches between named lists, nil if switching is disabled
+	lists *Lists
+
+	// navigation is the client's back/forward stack, shown while
+	// showNavigation is set. nil if it couldn't be loaded.
+	navigation     *storage.Navigation
+	showNavigation bool
+
+	// collapsed holds the groups folded into their header
+	collapsed map[string]bool
+}
+
+// Lists lets the UI switch between named session lists
+type Lists struct {
+	// Names are the available lists, in the order the UI cycles through them
+	Names []string
+	// Active is the name of the list being edited
+	Active string
+	// Switch makes the named list active and returns its sessions
+	Switch func(name string) ([]storage.SessionData, error)
+}
+
+// switchList saves the current list and moves step lists along, wrapping
+func (m model) switchList(step int) model {
+	if m.lists == nil || len(m.lists.Names) < 2 {
+		m.status = "No other lists, create one with 'rolo list new <name>'"
+		return m
+	}
+
+	current := 0
+	for i, name := range m.lists.Names {
+		if name == m.lists.Active {
+			current = i
+		}
+	}
+	count := len(m.lists.Names)
+	name := m.lists.Names[((current+step)%count+count)%count]
+
+	// Edits would be lost otherwise, the new list may not even be reachable
+	// again without leaving the UI
+	if m.onSave != nil {
+		if err := m.onSave(m.sessions); err != nil {
+			m.status = fmt.Sprintf("Failed to save '%s': %v", m.lists.Active, err)
+			return m
+		}
+	}
+	sessions, err := m.lists.Switch(name)
+	if err != nil {
+		m.status = fmt.Sprintf("Failed to switch to '%s': %v", name, err)
+		return m
+	}
+
+	m.lists.Active = name
+	m.sessions = sessions
+	m.cursor = 0
+	m = m.regroup()
+	m.mode = normalMode
+	m.status = fmt.Sprintf("Switched to list '%s'", name)
+	return m
+}
+
+// staleAfter is how long a session can be idle before it is shown as stale
+const staleAfter = 24 * time.Hour
+
+// liveSession returns the live tmux metadata for a stored session
+func (m model) liveSession(session storage.SessionData) (tmux.Session, bool) {
+	live, ok := tmux.FindSession(m.live, session.ID, session.Name)
+	if ok && live.ID == m.controlSession && live.Attached > 0 {
+		// Don't count rolo's own control client as an attached client
+		live.Attached--
+	}
+	return live, ok
+}
+
+// closed reports whether a session was live earlier but has since disappeared
+func (m model) closed(session storage.SessionData) bool {
+	if _, ok := m.liveSession(session); ok {
+		return false
+	}
+	return m.seen[session.ID] || m.seen["="+session.Name]
+}
+
+// markSeen records the currently live sessions
+func (m model) markSeen() {
+	for _, session := range m.live {
+		m.seen[session.ID] = true
+		m.seen["="+session.Name] = true
+	}
+}
+
+// tmuxEventMsg carries a notification from the control-mode watcher
+type tmuxEventMsg struct {
+	event tmux.Event
+	ok    bool
+}
+
+// liveSessionsMsg carries a fresh session listing from tmux
+type liveSessionsMsg struct {
+	sessions []tmux.Session
+	err      error
+}
+
+// waitForEvent blocks until the watcher delivers the next notification
+func waitForEvent(w tmux.Watcher) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-w.Events()
+		return tmuxEventMsg{event: event, ok: ok}
+	}
+}
+
+// refreshLive lists the sessions of the tmux server
+func refreshLive(client tmux.Client) tea.Cmd {
+	return func() tea.Msg {
+		sessions, err := client.ListSessions()
+		return liveSessionsMsg{sessions: sessions, err: err}
+	}
+}
+
+// applyLive updates the list from a live session listing: renamed sessions
+// are followed, missing sessions that are running again become active and
+// new sessions are appended, closed sessions are kept so they can be shown
+// as closed
+func (m model) applyLive(sessions []tmux.Session) model {
+	m.live = sessions
+
+	live := navigation.LiveSessions(sessions)
+	storage.FollowRenames(m.sessions, live)
+	storage.Resurrect(m.sessions, live, time.Now())
+
+	for _, session := range sessions {
+		if !m.seen[session.ID] && !m.seen["="+session.Name] && !m.tracked(session) {
+			m.sessions = append(m.sessions, storage.SessionData{
+				ID:      session.ID,
+				Name:    session.Name,
+				Created: session.Created,
+			})
+		}
+	}
+
+	m.markSeen()
+	return m.regroup()
+}
+
+// tracked reports whether a live session is already in the list
+func (m model) tracked(live tmux.Session) bool {
+	for _, session := range m.sessions {
+		if session.ID == live.ID || session.Name == live.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// grouped reports whether the list is shown under group headers
+func (m model) grouped() bool {
+	return storage.HasGroups(m.sessions)
+}
+
+// folded reports whether the entry at i is hidden in its collapsed group,
+// whose first entry stands for the group's header
+func (m model) folded(i int) bool {
+	if !m.grouped() || !m.collapsed[m.sessions[i].Group] {
+		return false
+	}
+	return i > 0 && m.sessions[i-1].Group == m.sessions[i].Group
+}
+
+// onCollapsed reports whether the cursor is on a collapsed group's header
+func (m model) onCollapsed() bool {
+	return m.cursor < len(m.sessions) && m.grouped() && m.collapsed[m.sessions[m.cursor].Group]
+}
+
+// regroup brings the entries of each group together, keeping the cursor on
+// its entry, and moves the cursor out of collapsed groups
+func (m model) regroup() model {
+	sessions := make([]storage.SessionData, 0, len(m.sessions))
+	cursor := m.cursor
+	for to, from := range storage.GroupOrder(m.sessions) {
+		sessions = append(sessions, m.sessions[from])
+		if from == m.cursor {
+			cursor = to
+		}
+	}
+	m.sessions = sessions
+	m.cursor = cursor
+
+	if m.cursor < len(m.sessions) && m.folded(m.cursor) {
+		m.cursor, _ = storage.GroupBounds(m.sessions, m.cursor)
+	}
+	return m
+}
+
+// step returns the next row down (1) or up (-1) from the cursor, skipping
+// folded entries and wrapping if enabled
+func (m model) step(direction int) int {
+	count := len(m.sessions)
+	i := m.cursor
+	for range count {
+		i += direction
+		if i < 0 || i >= count {
+			if !m.wrapAround {
+				return m.cursor
+			}
+			i = (i + count) % count
+		}
+		if !m.folded(i) {
+			return i
+		}
+	}
+	return m.cursor
+}
+
+// moveEntry moves the entry under the cursor one place down (1) or up
+// (-1). At the edge of its group it joins the neighbouring group instead,
+// so entries can be moved between groups.
+func (m model) moveEntry(direction int) model {
+	neighbour := m.cursor + direction
+	if neighbour < 0 || neighbour >= len(m.sessions) {
+		return m
+	}
+
+	session := &m.sessions[m.cursor]
+	if m.grouped() && m.sessions[neighbour].Group != session.Group {
+		session.Group = m.sessions[neighbour].Group
+		delete(m.collapsed, session.Group)
+		return m
+	}
+	m.sessions[m.cursor], m.sessions[neighbour] = m.sessions[neighbour], m.sessions[m.cursor]
+	m.cursor = neighbour
+	return m
+}
+
+// tmuxColor converts a tmux colour name to a terminal colour, "colour33" is
+// ANSI colour 33 and names such as "red" are the basic ANSI colours
+func tmuxColor(color string) lipgloss.Color {
+	if n, ok := strings.CutPrefix(strings.Replace(color, "colour", "color", 1), "color"); ok {
+		return lipgloss.Color(n)
+	}
+	names := map[string]string{
+		"black": "0", "red": "1", "green": "2", "yellow": "3",
+		"blue": "4", "magenta": "5", "cyan": "6", "white": "7",
+	}
+	if n, ok := names[color]; ok {
+		return lipgloss.Color(n)
+	}
+	return lipgloss.Color(color)
+}
+
+// viewNavigation renders the navigation stack oldest first, marking the
+// current visit; the visits rolo forward would go to are dimmed
+func (m model) viewNavigation(dim, current lipgloss.Style) string {
+	s := "Navigation (rolo back / rolo forward):\n"
+	if m.navigation == nil || len(m.navigation.Visits) == 0 {
+		return s + dim.Render("  nothing yet, switch sessions with rolo to fill it") + "\n"
+	}
+
+	for i, visit := range m.navigation.Visits {
+		line := fmt.Sprintf("%d. %s", i+1, visit.Name)
+		switch {
+		case i == m.navigation.Position:
+			s += current.Render("› "+line) + "\n"
+		case i > m.navigation.Position:
+			s += "  " + dim.Render(line) + "\n"
+		default:
+			s += "  " + line + "\n"
+		}
+	}
+	return s
+}
+
+// sinceLabel renders how long an entry has had its status, e.g. " 3d"
+func sinceLabel(session storage.SessionData, now time.Time) string {
+	if session.StatusSince.IsZero() {
+		return ""
+	}
+	return " " + tmux.FormatAge(now.Sub(session.StatusSince))
+}
+
+// sessionDetails summarises a live session, e.g. "3w · attached · idle 5m"
+func sessionDetails(session tmux.Session, now time.Time) string {
+	details := []string{fmt.Sprintf("%dw", session.Windows)}
+	switch {
+	case session.Attached == 1:
+		details = append(details, "attached")
+	case session.Attached > 1:
+		details = append(details, fmt.Sprintf("%d attached", session.Attached))
+	}
+	if session.Grouped {
+		details = append(details, "group "+session.Group)
+	}
+	if !session.Activity.IsZero() {
+		details = append(details, "idle "+tmux.FormatAge(session.Idle(now)))
+	}
+	return strings.Join(details, " · ")
 }
 
 func (m model) Init() tea.Cmd {
+	if m.watcher != nil {
+		return waitForEvent(m.watcher)
+	}
 	return nil
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tmuxEventMsg:
+		if !msg.ok {
+			// Watcher stopped, live updates are no longer available
+			m.watcher = nil
+			return m, nil
+		}
+		if msg.event.Kind == tmux.WatcherAttached {
+			m.controlSession = msg.event.SessionID
+			return m, waitForEvent(m.watcher)
+		}
+		return m, tea.Batch(waitForEvent(m.watcher), refreshLive(m.client))
+
+	case liveSessionsMsg:
+		if msg.err != nil {
+			// Keep showing the last known state
+			return m, nil
+		}
+		return m.applyLive(msg.sessions), nil
+
 	case tea.KeyMsg:
+		if m.editing {
+			return m.updateEdit(msg), nil
+		}
+		if m.confirmKill {
+			m.confirmKill = false
+			if msg.String() != "y" || m.onKill == nil || m.cursor >= len(m.sessions) {
+				m.status = ""
+				return m, nil
+			}
+			name := m.sessions[m.cursor].Name
+			if err := m.onKill(m.sessions[m.cursor]); err != nil {
+				m.status = fmt.Sprintf("Failed to kill '%s': %v", name, err)
+				return m, nil
+			}
+			// Only the entry goes, other edits stay unsaved as they were
+			m.sessions = append(m.sessions[:m.cursor:m.cursor], m.sessions[m.cursor+1:]...)
+			m.status = fmt.Sprintf("Killed '%s'", name)
+			if m.cursor >= len(m.sessions) && len(m.sessions) > 0 {
+				m.cursor = len(m.sessions) - 1
+			}
+			m = m.regroup()
+			return m, refreshLive(m.client)
+		}
+
+		switch msg.String() {
+		case "d", "e", "*", "x":
+			// These act on a single session, which a collapsed group hides
+			if m.onCollapsed() {
+				m.status = "Expand the group with z first"
+				return m, nil
+			}
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
 
 		case "d":
-			// Toggle deleted state for current session
+			// Hide the current session, or bring back a hidden, missing
+			// or archived one
+			if m.cursor < len(m.sessions) {
+				session := &m.sessions[m.cursor]
+				if session.Active() {
+					session.SetState(storage.StatusHidden, time.Now())
+				} else {
+					session.SetState(storage.StatusActive, time.Now())
+				}
+			}
+
+		case "e":
+			// Edit the metadata of the session under the cursor
 			if m.cursor < len(m.sessions) {
-				m.sessions[m.cursor].Deleted = !m.sessions[m.cursor].Deleted
+				m.editing = true
+				m.input = ""
+				m.status = ""
+			}
+
+		case "*":
+			// Toggle whether the session survives repopulating
+			if m.cursor < len(m.sessions) {
+				m.sessions[m.cursor].Pinned = !m.sessions[m.cursor].Pinned
+			}
+
+		case "x":
+			// Ask before killing the session under the cursor
+			if m.onKill != nil && m.cursor < len(m.sessions) {
+				m.confirmKill = true
+				m.status = fmt.Sprintf("Kill session '%s'? (y/n)", m.sessions[m.cursor].Name)
 			}
 
 		case "p":
 			// Repopulate from active tmux sessions
-			sessions, err := tmux.GetActiveSessions()
+			sessions, err := m.client.ListSessions()
 			if err != nil {
 				// If we can't get sessions, just keep current state
 				return m, nil
 			}
+			m.live = sessions
+			m.markSeen()
 			
-			// Convert to SessionData format (all non-deleted by default)
+			// Convert to SessionData format (all active by default)
 			sessionData := make([]storage.SessionData, len(sessions))
-			for i, name := range sessions {
-				sessionData[i] = storage.SessionData{Name: name, Deleted: false}
+			for i, session := range sessions {
+				sessionData[i] = storage.SessionData{ID: session.ID, Name: session.Name, Created: session.Created}
 			}
 			
-			// Replace current sessions and reset cursor
-			m.sessions = sessionData
+			// Replace current sessions, keeping metadata and pinned sessions,
+			// and reset cursor
+			m.sessions = storage.Repopulate(m.sessions, sessionData)
 			m.cursor = 0
 			if m.cursor >= len(m.sessions) && len(m.sessions) > 0 {
 				m.cursor = len(m.sessions) - 1
 			}
+			m = m.regroup()
 
 		case "u":
 			// Update list by adding new tmux sessions and removing closed ones
-			sessions, err := tmux.GetActiveSessions()
+			sessions, err := m.client.ListSessions()
 			if err != nil {
 				// If we can't get sessions, just keep current state
 				return m, nil
 			}
+			m.live = sessions
+			m.markSeen()
+			
+			// Follow renames so renamed sessions keep their position
+			live := navigation.LiveSessions(sessions)
+			storage.FollowRenames(m.sessions, live)
+			storage.Resurrect(m.sessions, live, time.Now())
 			
 			// Create a map of active session names for quick lookup
 			activeNames := make(map[string]bool)
-			for _, name := range sessions {
-				activeNames[name] = true
+			for _, session := range sessions {
+				activeNames[session.Name] = true
 			}
 			
-			// Filter out sessions that are no longer active
+			// Filter out sessions that are no longer active, unless pinned
 			filteredSessions := make([]storage.SessionData, 0, len(m.sessions))
 			for _, session := range m.sessions {
 				if activeNames[session.Name] {
 					filteredSessions = append(filteredSessions, session)
 					delete(activeNames, session.Name) // Remove from map so we know it's been seen
+				} else if session.Pinned {
+					filteredSessions = append(filteredSessions, session)
 				}
 			}
 			
-			// Add any new sessions that weren't in the list
-			for name := range activeNames {
-				filteredSessions = append(filteredSessions, storage.SessionData{
-					Name:    name,
-					Deleted: false,
-				})
+			// Add any new sessions that weren't in the list, in tmux order
+			for _, session := range sessions {
+				if activeNames[session.Name] {
+					filteredSessions = append(filteredSessions, storage.SessionData{
+						ID:      session.ID,
+						Name:    session.Name,
+						Created: session.Created,
+					})
+				}
 			}
 			
 			// Update sessions and adjust cursor if needed
@@ -128,6 +550,39 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.cursor >= len(m.sessions) && len(m.sessions) > 0 {
 				m.cursor = len(m.sessions) - 1
 			}
+			m = m.regroup()
+
+		case "b":
+			// Show or hide the back/forward navigation stack
+			m.showNavigation = !m.showNavigation
+
+		case "z":
+			// Collapse or expand the group under the cursor
+			if m.grouped() && m.cursor < len(m.sessions) {
+				group := m.sessions[m.cursor].Group
+				m.collapsed[group] = !m.collapsed[group]
+				if m.collapsed[group] {
+					m.cursor, _ = storage.GroupBounds(m.sessions, m.cursor)
+				}
+			}
+
+		case "J", "K":
+			// Move the whole group under the cursor past its neighbour
+			if m.mode == moveMode && m.grouped() && m.cursor < len(m.sessions) {
+				direction := 1
+				if msg.String() == "K" {
+					direction = -1
+				}
+				m.cursor = storage.MoveGroup(m.sessions, m.cursor, direction)
+			}
+
+		case "l", "L":
+			// Cycle through the named lists, saving the current one
+			step := 1
+			if msg.String() == "L" {
+				step = -1
+			}
+			m = m.switchList(step)
 
 		case "m":
 			// Toggle move mode
@@ -140,51 +595,85 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "j":
 			if m.mode == normalMode {
 				// Move cursor down
-				if m.cursor < len(m.sessions)-1 {
-					m.cursor++
-				} else if m.wrapAround && len(m.sessions) > 0 {
-					m.cursor = 0
-				}
+				m.cursor = m.step(1)
+			} else if m.onCollapsed() {
+				// A collapsed group moves as a whole
+				m.cursor = storage.MoveGroup(m.sessions, m.cursor, 1)
 			} else {
 				// Move item down
-				if m.cursor < len(m.sessions)-1 {
-					m.sessions[m.cursor], m.sessions[m.cursor+1] =
-						m.sessions[m.cursor+1], m.sessions[m.cursor]
-					m.cursor++
-				}
+				m = m.moveEntry(1)
 			}
 
 		case "k":
 			if m.mode == normalMode {
 				// Move cursor up
-				if m.cursor > 0 {
-					m.cursor--
-				} else if m.wrapAround && len(m.sessions) > 0 {
-					m.cursor = len(m.sessions) - 1
-				}
+				m.cursor = m.step(-1)
+			} else if m.onCollapsed() {
+				m.cursor = storage.MoveGroup(m.sessions, m.cursor, -1)
 			} else {
 				// Move item up
-				if m.cursor > 0 {
-					m.sessions[m.cursor], m.sessions[m.cursor-1] =
-						m.sessions[m.cursor-1], m.sessions[m.cursor]
-					m.cursor--
-				}
+				m = m.moveEntry(-1)
+			}
+
+		case "enter", "w":
+			// Enter on a collapsed group expands it rather than picking
+			// the session standing for its header
+			if msg.String() == "enter" && m.onCollapsed() {
+				delete(m.collapsed, m.sessions[m.cursor].Group)
+				return m, nil
 			}
 
-		case "enter":
-			// Save and quit
+			// Save and quit, enter also switches to the selected session
 			if m.onSave != nil {
 				if err := m.onSave(m.sessions); err != nil {
 					// Could add error handling here
 					return m, tea.Quit
 				}
 			}
+			if msg.String() == "enter" && m.cursor < len(m.sessions) && m.sessions[m.cursor].Active() {
+				selected := m.sessions[m.cursor]
+				m.selected = &selected
+			}
 			return m, tea.Quit
 		}
 	}
 	return m, nil
 }
 
+// updateEdit handles a key press while editing metadata
+func (m model) updateEdit(msg tea.KeyMsg) model {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.editing = false
+	case tea.KeyEnter:
+		m.editing = false
+		if m.cursor >= len(m.sessions) || strings.TrimSpace(m.input) == "" {
+			return m
+		}
+		session := &m.sessions[m.cursor]
+		field, value, err := storage.ParseMetaAssignment(m.input)
+		if err == nil {
+			err = session.SetMeta(field, value)
+		}
+		if err != nil {
+			m.status = err.Error()
+			return m
+		}
+		m.status = fmt.Sprintf("Set %s of '%s', press w to save", field, session.Name)
+		if field == "group" {
+			delete(m.collapsed, session.Group)
+			m = m.regroup()
+		}
+	case tea.KeyBackspace:
+		if runes := []rune(m.input); len(runes) > 0 {
+			m.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.input += string(msg.Runes)
+	}
+	return m
+}
+
 func (m model) View() string {
 	// Styles
 	titleStyle := lipgloss.NewStyle().
@@ -221,26 +710,72 @@ func (m model) View() string {
 	sessionActiveStyle := lipgloss.NewStyle().
 		Foreground(catppuccinText)
 	
-	sessionDeletedStyle := lipgloss.NewStyle().
+	sessionHiddenStyle := lipgloss.NewStyle().
 		Foreground(catppuccinOverlay0).
 		Strikethrough(true)
 	
+	sessionMissingStyle := lipgloss.NewStyle().
+		Foreground(catppuccinMaroon)
+	
+	sessionArchivedStyle := lipgloss.NewStyle().
+		Foreground(catppuccinSurface2).
+		Italic(true)
+	
+	sessionClosedStyle := lipgloss.NewStyle().
+		Foreground(catppuccinRed).
+		Bold(true)
+	
+	statusStyle := lipgloss.NewStyle().
+		Foreground(catppuccinYellow)
+	
+	warningStyle := lipgloss.NewStyle().
+		Foreground(catppuccinPeach).
+		Bold(true)
+	
+	detailStyle := lipgloss.NewStyle().
+		Foreground(catppuccinOverlay1)
+	
+	detailAttachedStyle := lipgloss.NewStyle().
+		Foreground(catppuccinTeal)
+	
+	detailStaleStyle := lipgloss.NewStyle().
+		Foreground(catppuccinYellow)
+	
+	pinnedStyle := lipgloss.NewStyle().
+		Foreground(catppuccinYellow)
+	
+	tagStyle := lipgloss.NewStyle().
+		Foreground(catppuccinLavender)
+	
+	notesStyle := lipgloss.NewStyle().
+		Foreground(catppuccinSubtext0).
+		Italic(true)
+	
 	sessionHighlightStyle := lipgloss.NewStyle().
 		Foreground(catppuccinText).
 		Background(catppuccinSurface0).
 		Bold(true)
 	
+	groupStyle := lipgloss.NewStyle().
+		Foreground(catppuccinMauve).
+		Bold(true)
+	
 	// Build the view
 	var s string
 	
 	// Title
-	s += titleStyle.Render("✨ Rolo - Tmux Session Manager") + "\n\n"
+	title := "✨ Rolo - Tmux Session Manager"
+	if m.lists != nil && len(m.lists.Names) > 1 {
+		title += " · " + m.lists.Active
+	}
+	s += titleStyle.Render(title) + "\n\n"
 	
 	// Mode indicator and help text
 	if m.mode == moveMode {
 		modeText := modeMoveStyle.Render("MOVE MODE")
 		help := helpStyle.Render(
 			keybindStyle.Render("j/k") + " move item  " +
+			keybindStyle.Render("J/K") + " move group  " +
 			keybindStyle.Render("m") + " exit move mode",
 		)
 		s += modeText + " - " + help + "\n\n"
@@ -248,16 +783,32 @@ func (m model) View() string {
 		modeText := modeNormalStyle.Render("NORMAL")
 		help := helpStyle.Render(
 			keybindStyle.Render("j/k") + " navigate  " +
-			keybindStyle.Render("d") + " delete  " +
+			keybindStyle.Render("d") + " hide  " +
+			keybindStyle.Render("x") + " kill  " +
+			keybindStyle.Render("e") + " edit  " +
+			keybindStyle.Render("*") + " pin  " +
 			keybindStyle.Render("u") + " update  " +
 			keybindStyle.Render("p") + " repopulate  " +
 			keybindStyle.Render("m") + " move  " +
-			keybindStyle.Render("enter") + " save",
+			keybindStyle.Render("l") + " lists  " +
+			keybindStyle.Render("b") + " back stack  " +
+			keybindStyle.Render("z") + " fold group  " +
+			keybindStyle.Render("w") + " save  " +
+			keybindStyle.Render("enter") + " save & switch",
 		)
 		s += modeText + " - " + help + "\n\n"
 	}
 
-	// Session list
+	// Duplicate and unnamed entries, by position
+	issues := storage.CheckSessions(m.sessions)
+	issueAt := make(map[int]storage.ListIssue, len(issues))
+	for _, issue := range issues {
+		issueAt[issue.Index] = issue
+	}
+	
+	// Session list, under a header per group once groups are used
+	now := time.Now()
+	grouped := m.grouped()
 	for i, session := range m.sessions {
 		var line string
 		
@@ -271,18 +822,108 @@ func (m model) View() string {
 			}
 		}
 		
-		// Session name with styling
-		sessionText := session.Name
-		if session.Deleted {
-			sessionText = sessionDeletedStyle.Render(session.Name)
-		} else if m.cursor == i {
-			sessionText = sessionHighlightStyle.Render(session.Name)
-		} else {
-			sessionText = sessionActiveStyle.Render(session.Name)
+		if grouped && (i == 0 || m.sessions[i-1].Group != session.Group) {
+			start, end := storage.GroupBounds(m.sessions, i)
+			header := fmt.Sprintf("%s (%d)", storage.GroupLabel(session.Group), end-start)
+			if m.collapsed[session.Group] {
+				// The collapsed group's header takes the place of its entries
+				s += cursor + groupStyle.Render("▸ "+header) + "\n"
+				continue
+			}
+			s += "  " + groupStyle.Render("▾ "+header) + "\n"
+		}
+		if m.folded(i) {
+			continue
+		}
+		if grouped {
+			cursor += "  "
+		}
+		
+		// Session name with styling, the alias replaces it when set
+		name := session.DisplayName()
+		sessionText := name
+		switch {
+		case session.State() == storage.StatusHidden:
+			sessionText = sessionHiddenStyle.Render(name)
+		case session.State() == storage.StatusMissing:
+			sessionText = sessionMissingStyle.Render(name)
+		case session.State() == storage.StatusArchived:
+			sessionText = sessionArchivedStyle.Render(name)
+		case m.cursor == i:
+			sessionText = sessionHighlightStyle.Render(name)
+		case session.Color != "":
+			sessionText = sessionActiveStyle.Foreground(tmuxColor(session.Color)).Render(name)
+		default:
+			sessionText = sessionActiveStyle.Render(name)
 		}
 		
 		line = cursor + sessionText
+		if session.Alias != "" {
+			line += " " + detailStyle.Render("("+session.Name+")")
+		}
+		if session.Pinned {
+			line += " " + pinnedStyle.Render("★")
+		}
+		
+		switch session.State() {
+		case storage.StatusMissing:
+			line += "  " + sessionMissingStyle.Render("missing"+sinceLabel(session, now))
+		case storage.StatusArchived:
+			line += "  " + sessionArchivedStyle.Render("archived"+sinceLabel(session, now))
+		case storage.StatusActive:
+			if m.closed(session) {
+				line += "  " + sessionClosedStyle.Render("✗ closed")
+			}
+		}
+		
+		if issue, ok := issueAt[i]; ok {
+			if issue.First == -1 {
+				line += "  " + warningStyle.Render("⚠ no name")
+			} else {
+				line += "  " + warningStyle.Render(fmt.Sprintf("⚠ duplicate of %d", issue.First+1))
+			}
+		}
+		
+		// Live tmux metadata
+		if live, ok := m.liveSession(session); ok && session.Active() {
+			style := detailStyle
+			if live.Attached > 0 {
+				style = detailAttachedStyle
+			} else if live.Idle(now) > staleAfter {
+				style = detailStaleStyle
+			}
+			line += "  " + style.Render(sessionDetails(live, now))
+		}
+		for _, tag := range session.Tags {
+			line += " " + tagStyle.Render("#"+tag)
+		}
 		s += line + "\n"
+		
+		// Notes can be long, only show them for the selected session
+		if m.cursor == i && session.Notes != "" {
+			for _, note := range strings.Split(session.Notes, "\n") {
+				s += "    " + notesStyle.Render(note) + "\n"
+			}
+		}
+	}
+	
+	// Metadata prompt
+	if m.editing {
+		s += "\n" + keybindStyle.Render("field=value") + helpStyle.Render(" ("+strings.Join(storage.MetaFields, ", ")+", esc cancels): ") + m.input + "█\n"
+	}
+	
+	if m.showNavigation {
+		s += "\n" + m.viewNavigation(detailStyle, cursorNormalStyle)
+	}
+	
+	// Duplicates make navigation ambiguous, only the first entry is used
+	if len(issues) > 0 {
+		s += "\n" + warningStyle.Render(fmt.Sprintf("⚠ %d duplicate or unnamed entries, only the first entry of a session is used; run 'rolo doctor --fix'", len(issues))) + "\n"
+	}
+	
+	// Status line
+	if m.status != "" {
+		s += "\n" + statusStyle.Render(m.status) + "\n"
 	}
 	
 	// Footer
@@ -292,26 +933,60 @@ func (m model) View() string {
 }
 
 // Run starts the interactive TUI for reordering sessions
-func Run(sessions []storage.SessionData, onSave func([]storage.SessionData) error) error {
+// onKill kills a session and removes its entry from the saved list, leaving
+// the UI's unsaved edits to onSave. It may be nil to disable killing from
+// the UI. lists may be nil to disable
+// switching lists, and navigation is the client's back/forward stack shown
+// with b, nil if unknown.
+// Returns the session the user chose to switch to, or nil if they only
+// saved or quit
+func Run(client tmux.Client, sessions []storage.SessionData, onSave func([]storage.SessionData) error, onKill func(storage.SessionData) error, lists *Lists, navigation *storage.Navigation) (*storage.SessionData, error) {
 	// Load config to get wrap around setting
 	config, err := storage.LoadConfig()
 	if err != nil {
 		// If config fails to load, use default (false)
-		config = &storage.Config{WrapAround: false}
+		config = storage.DefaultConfig()
+	}
+
+	// Live metadata is best effort, the list still works without it
+	live, err := client.ListSessions()
+	if err != nil {
+		live = nil
+	}
+
+	// Live updates are best effort too, e.g. control mode needs a running server
+	watcher, err := client.Watch()
+	if err != nil {
+		watcher = nil
 	}
 
 	m := model{
+		client:     client,
+		watcher:    watcher,
+		live:       live,
+		seen:       make(map[string]bool),
 		sessions:   sessions,
 		cursor:     0,
 		mode:       normalMode,
 		onSave:     onSave,
+		onKill:     onKill,
 		wrapAround: config.WrapAround,
+		lists:      lists,
+		navigation: navigation,
+		collapsed:  make(map[string]bool),
+	}
+	m = m.regroup()
+
+	m.markSeen()
+	if watcher != nil {
+		defer watcher.Close()
 	}
 
 	p := tea.NewProgram(m)
-	if _, err := p.Run(); err != nil {
-		return fmt.Errorf("TUI error: %w", err)
+	final, err := p.Run()
+	if err != nil {
+		return nil, fmt.Errorf("TUI error: %w", err)
 	}
 
-	return nil
+	return final.(model).selected, nil
 }